@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 
@@ -20,6 +21,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
@@ -39,6 +41,9 @@ const (
 	EnvVarNamespaces = "ROTATOR_NAMESPACES"
 )
 
+// defaultJWKSMaxAge is the default max-age of the responses of the JWKS endpoint.
+const defaultJWKSMaxAge = 5 * time.Minute
+
 //nolint:funlen
 func main() {
 	setupLog := ctrl.Log.WithName("setup")
@@ -50,6 +55,12 @@ func main() {
 	var secureMetrics bool
 	var enableHTTP2 bool
 	var namespacesCli string
+	var dryRun bool
+	var keyEncodingCli string
+	var keyPolicy controller.KeyPolicy
+	var allowedCurvesCli string
+	var jwksAddr string
+	var jwksMaxAge time.Duration
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(
 		&metricsAddr,
@@ -113,6 +124,43 @@ func main() {
 		"",
 		"Comma separated list of namespaces to watch. If not set, all namespaces will be watched.",
 	)
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"If set, the full reconciliation is run without creating or updating any secrets. "+
+			"Planned rotations are logged instead. Uses a separate leader election lease, "+
+			"so it can run next to an active operator.")
+	flag.StringVar(
+		&keyEncodingCli,
+		"key-encoding",
+		string(controller.KeyEncodingKeep),
+		"The encoding private keys are normalized into when they enter the target secrets: "+
+			"keep, pkcs1, pkcs8 or sec1. Can be overridden per source secret.",
+	)
+	flag.IntVar(
+		&keyPolicy.MinRSABits,
+		"policy-min-rsa-bits",
+		0,
+		"The minimum size of RSA keys rotated into target secrets. Set to 0 to allow any size.",
+	)
+	flag.StringVar(
+		&allowedCurvesCli,
+		"policy-allowed-curves",
+		"",
+		"Comma separated list of the curves of keys rotated into target secrets. If empty, all curves are allowed.",
+	)
+	flag.BoolVar(&keyPolicy.RejectSHA1, "policy-reject-sha1", false,
+		"If set, certificates signed with SHA-1 are not rotated into target secrets.")
+	flag.DurationVar(&keyPolicy.MaxLifetime, "policy-max-lifetime", 0,
+		"The maximum validity period of certificates rotated into target secrets. Set to 0 to allow any lifetime.")
+	flag.StringVar(
+		&jwksAddr,
+		"jwks-bind-address",
+		"0",
+		"The address the JWKS endpoint serving /jwks/{namespace}/{target} binds to, e.g. :8082. "+
+			"Leave as 0 to disable the JWKS endpoint.",
+	)
+	flag.DurationVar(&jwksMaxAge, "jwks-max-age", defaultJWKSMaxAge,
+		"The max-age of the responses of the JWKS endpoint. "+
+			"For sources with a min-next-age, half of it is used if it is shorter.")
 
 	opts := zap.Options{
 		Development: true,
@@ -229,13 +277,19 @@ func main() {
 		)
 	}
 
+	leaderElectionID := "62f83323.rotator.gw.ei.telekom.de"
+	if dryRun {
+		setupLog.Info("running in dry-run mode, no secrets will be created or updated")
+		leaderElectionID = "dry-run." + leaderElectionID
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsServerOptions,
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "62f83323.rotator.gw.ei.telekom.de",
+		LeaderElectionID:       leaderElectionID,
 		Cache: cache.Options{
 			DefaultNamespaces: namespacesMap,
 		},
@@ -256,12 +310,25 @@ func main() {
 		os.Exit(1)
 	}
 
+	keyPolicy.AllowedCurves = controller.ParseCurves(allowedCurvesCli)
+
+	keyEncoding, err := controller.ParseKeyEncoding(keyEncodingCli)
+	if err != nil {
+		setupLog.Error(err, "invalid --key-encoding")
+		os.Exit(1)
+	}
+
 	if err = (&controller.SecretReconciler{
 		Client:               mgr.GetClient(),
 		Scheme:               mgr.GetScheme(),
+		Recorder:             mgr.GetEventRecorder("secret-rotator"),
+		Clock:                clock.RealClock{},
 		SourceAnnotation:     "rotator.gw.ei.telekom.de/source-secret",
 		TargetNameAnnotation: "rotator.gw.ei.telekom.de/destination-secret-name",
 		Finalizer:            "rotator.gw.ei.telekom.de/finalizer",
+		KeyEncoding:          keyEncoding,
+		KeyPolicy:            keyPolicy,
+		DryRun:               dryRun,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Secret")
 		os.Exit(1)
@@ -284,6 +351,18 @@ func main() {
 		}
 	}
 
+	if jwksAddr != "0" {
+		setupLog.Info("Adding JWKS endpoint to manager")
+		if err = mgr.Add(&controller.JWKSServer{
+			BindAddress: jwksAddr,
+			Reader:      mgr.GetClient(),
+			MaxAge:      jwksMaxAge,
+		}); err != nil {
+			setupLog.Error(err, "unable to add JWKS endpoint to manager")
+			os.Exit(1)
+		}
+	}
+
 	if err = mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
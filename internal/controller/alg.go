@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// algAnnotation can be set on a source secret to override the JWS algorithm detected from its key,
+// e.g. to use PS256 instead of RS256 for an RSA key.
+const algAnnotation = "rotator.gw.ei.telekom.de/alg"
+
+// compatibleAlgs returns the JWS algorithms that can be used with the given public key.
+// The first algorithm is the one detected by default.
+func compatibleAlgs(pub crypto.PublicKey) ([]string, error) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}, nil
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return []string{"ES256"}, nil
+		case elliptic.P384():
+			return []string{"ES384"}, nil
+		case elliptic.P521():
+			return []string{"ES512"}, nil
+		default:
+			return nil, fmt.Errorf("unsupported EC curve %s", key.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		return []string{"EdDSA"}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key of type %T", pub)
+	}
+}
+
+// deriveAlg returns the JWS algorithm of the given certificate of the source. It is detected from the key type, unless
+// the source overrides it with an algorithm compatible with the key.
+// The certificate has to be validated before.
+func deriveAlg(source *corev1.Secret, crt []byte) (string, error) {
+	cert, err := parseCertificate(crt)
+	if err != nil {
+		return "", err
+	}
+	algs, err := compatibleAlgs(cert.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	alg, ok := source.Annotations[algAnnotation]
+	if !ok {
+		return algs[0], nil
+	}
+	if !slices.Contains(algs, alg) {
+		return "", fmt.Errorf("%s must be one of %s for the key of the source certificate, got %q",
+			algAnnotation, strings.Join(algs, ", "), alg)
+	}
+	return alg, nil
+}
@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"bytes"
+	"maps"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// rotationGenerationAnnotation is set on the target secret and counts the rotations and rollbacks of its slots.
+	rotationGenerationAnnotation = "rotator.gw.ei.telekom.de/rotation-generation"
+	// lastRotationTimeAnnotation is set on the target secret and records when its slots were last rotated.
+	lastRotationTimeAnnotation = "rotator.gw.ei.telekom.de/last-rotation-time"
+	// sourceResourceVersionAnnotation is set on the target secret and records the resourceVersion of the source
+	// that triggered the last rotation.
+	sourceResourceVersionAnnotation = "rotator.gw.ei.telekom.de/source-resource-version"
+	// sourceUIDAnnotation is set on the target secret and records the UID of the source that triggered the
+	// last rotation.
+	sourceUIDAnnotation = "rotator.gw.ei.telekom.de/source-uid"
+)
+
+// publishedAtAnnotation returns the annotation of the target secret that records when the given slot was filled
+// with its current key, e.g. rotator.gw.ei.telekom.de/tls-published-at for the time the key became active.
+func publishedAtAnnotation(slot string) string {
+	return "rotator.gw.ei.telekom.de/" + slot + "-published-at"
+}
+
+// formatTime formats the given time for the annotations of the target secret.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// slotsChanged returns whether the slot data differs between previous and updated. The outputs derived from the
+// slots, e.g. jwks.json, are ignored.
+func slotsChanged(previous map[string][]byte, updated map[string][]byte) bool {
+	return !maps.EqualFunc(slotData(previous), slotData(updated), bytes.Equal)
+}
+
+// recordSlotChanges records the given time as the publish time of every slot whose kid differs from the previous
+// data. The publish times of emptied or dropped slots are removed. It does not update the secret in the cluster.
+func recordSlotChanges(target *corev1.Secret, previous map[string][]byte, now time.Time) {
+	for _, name := range existingSlotNames(previous) {
+		if _, ok := target.Data[name+".kid"]; !ok {
+			delete(target.Annotations, publishedAtAnnotation(name))
+		}
+	}
+	for _, name := range existingSlotNames(target.Data) {
+		kid := readSlot(target.Data, name).kid
+		switch {
+		case len(kid) == 0:
+			delete(target.Annotations, publishedAtAnnotation(name))
+		case !bytes.Equal(kid, previous[name+".kid"]):
+			metav1.SetMetaDataAnnotation(&target.ObjectMeta, publishedAtAnnotation(name), formatTime(now))
+		}
+	}
+}
+
+// recordRotation increments the rotation generation of the target and records the time of the rotation as well as
+// the source that triggered it. An invalid generation is restarted at 1. It does not update the secret in the cluster.
+func recordRotation(target *corev1.Secret, source *corev1.Secret, now time.Time) {
+	generation, err := strconv.Atoi(target.Annotations[rotationGenerationAnnotation])
+	if err != nil {
+		generation = 0
+	}
+	metav1.SetMetaDataAnnotation(&target.ObjectMeta, rotationGenerationAnnotation, strconv.Itoa(generation+1))
+	metav1.SetMetaDataAnnotation(&target.ObjectMeta, lastRotationTimeAnnotation, formatTime(now))
+	metav1.SetMetaDataAnnotation(&target.ObjectMeta, sourceResourceVersionAnnotation, source.ResourceVersion)
+	metav1.SetMetaDataAnnotation(&target.ObjectMeta, sourceUIDAnnotation, string(source.UID))
+}
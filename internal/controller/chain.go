@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// parseCertificateChain returns the PEM encoded certificates in the given data, starting with the leaf certificate.
+func parseCertificateChain(crt []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := crt
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate %d in tls.crt: %w", len(certs)+1, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("tls.crt does not contain a PEM encoded certificate")
+	}
+	return certs, nil
+}
+
+// splitChain splits the PEM encoded certificates in the given data into the leaf certificate and the chain following
+// it, both PEM encoded, and the x5c of all certificates, a JSON array of the base64 encoded DER certificates starting
+// with the leaf as defined in RFC 7517. The chain is empty for self-signed and single certificates.
+func splitChain(crt []byte) ([]byte, []byte, []byte, error) {
+	certs, err := parseCertificateChain(crt)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	leaf := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certs[0].Raw})
+	var chain []byte
+	x5c := make([]string, 0, len(certs))
+	for i, cert := range certs {
+		if i > 0 {
+			chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+		}
+		x5c = append(x5c, base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+
+	encoded, err := json.Marshal(x5c)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("marshaling x5c: %w", err)
+	}
+	return leaf, chain, encoded, nil
+}
@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// slotChange describes how the kid in a single slot of the target changes.
+type slotChange struct {
+	Slot string `json:"slot"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// slotDiff returns the changes of the kids in all slots between the previous and the updated target data.
+func slotDiff(previous map[string][]byte, updated map[string][]byte) []slotChange {
+	var changes []slotChange
+	names := existingSlotNames(updated)
+	for _, name := range existingSlotNames(previous) {
+		if _, ok := updated[name+".kid"]; !ok {
+			// Slot has been dropped, e.g. because the number of retained keys has been reduced
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		from := string(readSlot(previous, name).kid)
+		to := string(readSlot(updated, name).kid)
+		if from != to {
+			changes = append(changes, slotChange{Slot: name, From: from, To: to})
+		}
+	}
+	return changes
+}
+
+// logSlotDiff logs the changes of the kids in the target, if the reconciler runs in dry-run mode.
+func (r *SecretReconciler) logSlotDiff(ctx context.Context, previous map[string][]byte, updated map[string][]byte) {
+	if !r.DryRun {
+		return
+	}
+	logf.FromContext(ctx).Info("Dry run, planned changes of the target secret", "diff", slotDiff(previous, updated))
+}
+
+// create creates the object in the cluster. In dry-run mode, the object is not created.
+func (r *SecretReconciler) create(ctx context.Context, obj client.Object) error {
+	if r.DryRun {
+		logf.FromContext(ctx).Info("Dry run, skipping create", "object", client.ObjectKeyFromObject(obj))
+		return nil
+	}
+	return r.Create(ctx, obj)
+}
+
+// update updates the object in the cluster. In dry-run mode, the object is not updated.
+func (r *SecretReconciler) update(ctx context.Context, obj client.Object) error {
+	if r.DryRun {
+		logf.FromContext(ctx).Info("Dry run, skipping update", "object", client.ObjectKeyFromObject(obj))
+		return nil
+	}
+	return r.Update(ctx, obj)
+}
+
+// delete deletes the object in the cluster. In dry-run mode, the object is not deleted.
+func (r *SecretReconciler) delete(ctx context.Context, obj client.Object) error {
+	if r.DryRun {
+		logf.FromContext(ctx).Info("Dry run, skipping delete", "object", client.ObjectKeyFromObject(obj))
+		return nil
+	}
+	return r.Delete(ctx, obj)
+}
+
+// dryRunRecorder logs events instead of recording them in the cluster.
+type dryRunRecorder struct {
+	log logr.Logger
+}
+
+// Eventf logs the event.
+func (d dryRunRecorder) Eventf(
+	regarding runtime.Object,
+	_ runtime.Object,
+	eventtype, reason, action, note string,
+	args ...any,
+) {
+	var regardingKey client.ObjectKey
+	if obj, ok := regarding.(client.Object); ok {
+		regardingKey = client.ObjectKeyFromObject(obj)
+	}
+	d.log.Info("Dry run, skipping event", "regarding", regardingKey, "type", eventtype, "reason", reason,
+		"action", action, "note", fmt.Sprintf(note, args...))
+}
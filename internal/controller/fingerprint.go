@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/pem"
+
+	"github.com/google/uuid"
+)
+
+// canonicalCert returns the DER bytes of all certificates in the PEM encoded data, i.e. the leaf followed by its
+// chain, so that the same certificates are recognized regardless of their PEM encoding, e.g. line breaks or
+// surrounding whitespace. Data without a PEM encoded certificate is returned with surrounding whitespace trimmed.
+func canonicalCert(crt []byte) []byte {
+	var der []byte
+	rest := crt
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			der = append(der, block.Bytes...)
+		}
+	}
+	if der == nil {
+		return bytes.TrimSpace(crt)
+	}
+	return der
+}
+
+// generateKid returns the kid of the given certificate, a UUID generated from the PEM encoded certificate as read
+// from the source. The PEM encoding is used instead of the canonical form, so that the kids of existing targets
+// don't change.
+func generateKid(crt []byte) uuid.UUID {
+	return uuid.NewSHA1(uuid.Nil, crt)
+}
+
+// sameCert returns whether both values hold the same certificate, ignoring differences in the PEM encoding.
+func sameCert(a []byte, b []byte) bool {
+	return len(a) > 0 && len(b) > 0 && bytes.Equal(canonicalCert(a), canonicalCert(b))
+}
+
+// slotOfCert returns the name of the slot in the secret data that holds the given certificate.
+// It returns false if no slot holds it.
+func slotOfCert(data map[string][]byte, crt []byte) (string, bool) {
+	for _, name := range existingSlotNames(data) {
+		if sameCert(readSlot(data, name).crt, crt) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// slotOfLeaf returns the name of the slot in the secret data whose leaf certificate is the leaf of the given
+// certificate, even if their chains differ. It returns false if no slot holds it.
+func slotOfLeaf(data map[string][]byte, crt []byte) (string, bool) {
+	leaf, err := parseCertificate(crt)
+	if err != nil {
+		return "", false
+	}
+	for _, name := range existingSlotNames(data) {
+		if cert, certErr := parseCertificate(readSlot(data, name).crt); certErr == nil && cert.Equal(leaf) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// slotOfKid returns the name of the slot in the secret data that holds the given kid.
+// It returns false if no slot holds it.
+func slotOfKid(data map[string][]byte, kid string) (string, bool) {
+	for _, name := range existingSlotNames(data) {
+		if kid != "" && string(readSlot(data, name).kid) == kid {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// samePublicKey returns whether both values hold PEM encoded certificates with the same public key.
+func samePublicKey(a []byte, b []byte) bool {
+	certA, err := parseCertificate(a)
+	if err != nil {
+		return false
+	}
+	certB, err := parseCertificate(b)
+	if err != nil {
+		return false
+	}
+	public, ok := certA.PublicKey.(interface{ Equal(x crypto.PublicKey) bool })
+	return ok && public.Equal(certB.PublicKey)
+}
+
+// duplicateKids returns the kids that are held by more than one slot in the secret data.
+func duplicateKids(data map[string][]byte) []string {
+	seen := map[string]bool{}
+	var duplicates []string
+	for _, name := range existingSlotNames(data) {
+		kid := string(readSlot(data, name).kid)
+		if kid == "" {
+			continue
+		}
+		if seen[kid] {
+			duplicates = append(duplicates, kid)
+		}
+		seen[kid] = true
+	}
+	return duplicates
+}
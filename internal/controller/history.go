@@ -0,0 +1,285 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// historySizeAnnotation can be set on a source secret to configure how many rotations can be rolled back.
+	historySizeAnnotation = "rotator.gw.ei.telekom.de/history-size"
+	// maxHistorySize limits the number of recorded rotations to keep the history secret reasonably small.
+	maxHistorySize = 10
+	// rollbackRequestAnnotation can be set on a source secret to roll back the last recorded rotation.
+	// Every new value of the annotation triggers exactly one rollback.
+	rollbackRequestAnnotation = "rotator.gw.ei.telekom.de/rollback-request"
+	// lastRollbackRequestAnnotation is set on the target secret and records the last handled rollback request.
+	lastRollbackRequestAnnotation = "rotator.gw.ei.telekom.de/last-rollback-request"
+	// blockedKidsAnnotation is set on the target secret and lists the kids of sources whose rotation has been
+	// rolled back. They are not rotated into the target again until they are removed from the annotation.
+	blockedKidsAnnotation = "rotator.gw.ei.telekom.de/blocked-kids"
+	// poppedSnapshot is the number of the snapshot that has been popped last from the history secret. It is kept
+	// until the next snapshot is pushed, so that a retried rollback restores the same snapshot.
+	poppedSnapshot = 0
+)
+
+// historySize returns the number of rotations that can be rolled back, as configured on the source.
+func historySize(source *corev1.Secret) (int, error) {
+	val, ok := source.Annotations[historySizeAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", historySizeAnnotation, err)
+	}
+	if n < 0 || n > maxHistorySize {
+		return 0, fmt.Errorf("%s must be between 0 and %d, got %d", historySizeAnnotation, maxHistorySize, n)
+	}
+	return n, nil
+}
+
+// historySecretName returns the name of the secret holding the rotation history of the given target.
+func historySecretName(target *corev1.Secret) string {
+	return target.Name + "-history"
+}
+
+// pushSnapshot records the slots of the given target data as the newest snapshot in the history secret.
+// Snapshots are stored with a numeric prefix, 1 being the newest, e.g. 1.tls.crt. Snapshots beyond the given size
+// and the snapshot popped last are dropped. The outputs derived from the slots, e.g. jwks.json or the keystores, are
+// not recorded, as they are rendered again when a snapshot is restored and would bloat the history secret.
+// It does not update the secret in the cluster.
+func pushSnapshot(history *corev1.Secret, data map[string][]byte, size int) {
+	updatedData := map[string][]byte{}
+	for key, val := range history.Data {
+		n, rest, ok := splitSnapshotKey(key)
+		if ok && n != poppedSnapshot && n < size {
+			updatedData[strconv.Itoa(n+1)+"."+rest] = val
+		}
+	}
+	for key, val := range slotData(data) {
+		updatedData["1."+key] = val
+	}
+	history.Data = updatedData
+	delete(history.Annotations, lastRollbackRequestAnnotation)
+}
+
+// popSnapshot removes the newest snapshot from the history secret for the given rollback request and returns it.
+// The popped snapshot is kept as snapshot 0 and the request is recorded in the annotations of the history secret,
+// so that retrying the same request, e.g. because the update of the target failed, returns the same snapshot instead
+// of popping the next one. It returns false if the history is empty. It does not update the secret in the cluster.
+func popSnapshot(history *corev1.Secret, request string) (map[string][]byte, bool) {
+	retried := history.Annotations[lastRollbackRequestAnnotation] == request
+	snapshot := map[string][]byte{}
+	updatedData := map[string][]byte{}
+	for key, val := range history.Data {
+		n, rest, ok := splitSnapshotKey(key)
+		switch {
+		case !ok:
+			continue
+		case retried:
+			if n == poppedSnapshot {
+				snapshot[rest] = val
+			}
+			updatedData[key] = val
+		case n == poppedSnapshot:
+			// The snapshot popped by the previous request is dropped
+			continue
+		default:
+			if n == 1 {
+				snapshot[rest] = val
+			}
+			updatedData[strconv.Itoa(n-1)+"."+rest] = val
+		}
+	}
+	if len(snapshot) == 0 {
+		return nil, false
+	}
+	history.Data = updatedData
+	metav1.SetMetaDataAnnotation(&history.ObjectMeta, lastRollbackRequestAnnotation, request)
+	return snapshot, true
+}
+
+// splitSnapshotKey splits a key of the history secret into the snapshot number and the key in the target.
+func splitSnapshotKey(key string) (int, string, bool) {
+	prefix, rest, ok := strings.Cut(key, ".")
+	if !ok {
+		return 0, "", false
+	}
+	n, err := strconv.Atoi(prefix)
+	if err != nil || n < poppedSnapshot {
+		return 0, "", false
+	}
+	return n, rest, true
+}
+
+// pendingRollbackRequest returns the rollback request of the source and whether it has not been handled yet.
+func pendingRollbackRequest(source *corev1.Secret, target *corev1.Secret) (string, bool) {
+	request, ok := source.Annotations[rollbackRequestAnnotation]
+	if !ok || request == "" {
+		return "", false
+	}
+	return request, request != target.Annotations[lastRollbackRequestAnnotation]
+}
+
+// isBlocked returns whether the given kid has been blocked in the target by a rollback.
+func isBlocked(target *corev1.Secret, kid string) bool {
+	return slices.Contains(splitKids(target.Annotations[blockedKidsAnnotation]), kid)
+}
+
+// blockKid adds the given kid to the blocked kids of the target.
+func blockKid(target *corev1.Secret, kid string) {
+	if kid == "" || isBlocked(target, kid) {
+		return
+	}
+	blocked := append(splitKids(target.Annotations[blockedKidsAnnotation]), kid)
+	metav1.SetMetaDataAnnotation(&target.ObjectMeta, blockedKidsAnnotation, strings.Join(blocked, ","))
+}
+
+// getHistory returns the history secret of the target and whether it exists. A new history secret is returned if it
+// doesn't exist. An existing secret that is not controlled by the source is refused with an error and a warning event,
+// so that a secret of the user that happens to have the name of the history secret is never overwritten or adopted.
+func (r *SecretReconciler) getHistory(
+	ctx context.Context,
+	source *corev1.Secret,
+	target *corev1.Secret,
+	action string,
+) (*corev1.Secret, bool, error) {
+	log := logf.FromContext(ctx)
+
+	history := &corev1.Secret{}
+	historyNamespacedName := types.NamespacedName{Namespace: target.Namespace, Name: historySecretName(target)}
+	err := r.Get(ctx, historyNamespacedName, history)
+	if errors.IsNotFound(err) {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      historyNamespacedName.Name,
+				Namespace: historyNamespacedName.Namespace,
+			},
+			Type: corev1.SecretTypeOpaque,
+		}, false, nil
+	}
+	if err != nil {
+		log.Error(err, "Failed to get history secret")
+		return nil, false, err
+	}
+	if !metav1.IsControlledBy(history, source) {
+		err = fmt.Errorf("history secret %s is not controlled by source secret %s", history.Name, source.Name)
+		log.Error(err, "Refusing to use the history secret")
+		r.Recorder.Eventf(source, nil, corev1.EventTypeWarning, "HistoryConflict", action,
+			"Refusing to use history secret %s, it is not controlled by the source", history.Name)
+		return nil, false, err
+	}
+	return history, true, nil
+}
+
+// recordHistory records the given previous target data as a snapshot in the history secret of the target,
+// creating the history secret if necessary. Nothing is recorded if the history is disabled for the source.
+func (r *SecretReconciler) recordHistory(
+	ctx context.Context,
+	source *corev1.Secret,
+	target *corev1.Secret,
+	previous map[string][]byte,
+	opts rotationOptions,
+) error {
+	if opts.historySize == 0 {
+		return nil
+	}
+	log := logf.FromContext(ctx)
+
+	history, historyExists, err := r.getHistory(ctx, source, target, "Rotate")
+	if err != nil {
+		return err
+	}
+
+	pushSnapshot(history, previous, opts.historySize)
+	if err = controllerutil.SetControllerReference(source, history, r.Scheme); err != nil {
+		log.Error(err, "Failed to set controller reference on history secret")
+		return err
+	}
+
+	if historyExists {
+		err = r.update(ctx, history)
+	} else {
+		err = r.create(ctx, history)
+	}
+	if err != nil {
+		log.Error(err, "Failed to write history secret")
+		return err
+	}
+	return nil
+}
+
+// rollbackTarget restores the target secret from the newest snapshot in its history and updates both in the cluster.
+// The kid of the source that triggered the rolled back rotation is blocked, so it is not rotated in again.
+// The history is updated first, the pop is keyed on the request, so that a failed update of the target is retried
+// with the same snapshot.
+func (r *SecretReconciler) rollbackTarget(
+	ctx context.Context,
+	source *corev1.Secret,
+	target *corev1.Secret,
+	request string,
+	now time.Time,
+) (ctrl.Result, error) {
+	log := logf.FromContext(ctx).WithValues("rollbackRequest", request)
+
+	history, _, err := r.getHistory(ctx, source, target, "Rollback")
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	metav1.SetMetaDataAnnotation(&target.ObjectMeta, lastRollbackRequestAnnotation, request)
+	snapshot, ok := popSnapshot(history, request)
+	if !ok {
+		log.Info("Cannot roll back target secret, no rotation has been recorded")
+		r.Recorder.Eventf(target, source, corev1.EventTypeWarning, "RollbackFailed", "Rollback",
+			"Cannot roll back, no rotation has been recorded in %s", history.Name)
+	} else {
+		blockKid(target, string(target.Data["next-tls.kid"]))
+		r.logSlotDiff(ctx, target.Data, snapshot)
+		previous := target.Data
+		target.Data = maps.Clone(snapshot)
+		if purged := purgeRevokedSlots(target); len(purged) > 0 {
+			log.Info("Removed revoked keys from restored target secret", "slots", purged)
+		}
+		recordSlotChanges(target, previous, now)
+		recordRotation(target, source, now)
+		if err = r.renderTargetOutputs(ctx, source, target); err != nil {
+			log.Error(err, "Failed to render the outputs of the target secret")
+			return ctrl.Result{}, err
+		}
+
+		if err = r.update(ctx, history); err != nil {
+			log.Error(err, "Failed to update history secret")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err = r.update(ctx, target); err != nil {
+		log.Error(err, "Failed to update target secret")
+		return ctrl.Result{}, err
+	}
+	if ok {
+		log.Info("Successfully rolled back target secret")
+		r.Recorder.Eventf(target, source, corev1.EventTypeNormal, "RolledBack", "Rollback",
+			"Rolled back the last rotation")
+	}
+	return ctrl.Result{}, nil
+}
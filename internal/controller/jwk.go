@@ -0,0 +1,210 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// jwkPublicKey holds the required members of a public JWK as defined in RFC 7518 and RFC 8037.
+// The fields are ordered lexicographically, so that the marshaled JSON is the input of the RFC 7638 thumbprint.
+type jwkPublicKey struct {
+	Crv string `json:"crv,omitempty"`
+	E   string `json:"e,omitempty"`
+	Kty string `json:"kty"`
+	N   string `json:"n,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// base64URL encodes the given bytes as unpadded base64url, as used in JWKs.
+func base64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// newJWKPublicKey returns the required JWK members of the given RSA, EC or Ed25519 public key.
+func newJWKPublicKey(pub crypto.PublicKey) (jwkPublicKey, error) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return jwkPublicKey{
+			Kty: "RSA",
+			N:   base64URL(key.N.Bytes()),
+			E:   base64URL(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		raw, err := key.Bytes()
+		if err != nil {
+			return jwkPublicKey{}, fmt.Errorf("encoding EC public key: %w", err)
+		}
+		// The uncompressed point is 0x04 followed by the x and y coordinates of the same size
+		size := (len(raw) - 1) / 2 //nolint:mnd // two coordinates
+		return jwkPublicKey{
+			Kty: "EC",
+			Crv: key.Curve.Params().Name,
+			X:   base64URL(raw[1 : 1+size]),
+			Y:   base64URL(raw[1+size:]),
+		}, nil
+	case ed25519.PublicKey:
+		return jwkPublicKey{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64URL(key),
+		}, nil
+	default:
+		return jwkPublicKey{}, fmt.Errorf("unsupported public key of type %T", pub)
+	}
+}
+
+// jwkThumbprint returns the RFC 7638 JWK thumbprint of the given public key, using SHA-256 and base64url.
+func jwkThumbprint(pub crypto.PublicKey) (string, error) {
+	jwk, err := newJWKPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	members, err := json.Marshal(jwk)
+	if err != nil {
+		return "", fmt.Errorf("marshaling JWK: %w", err)
+	}
+	sum := sha256.Sum256(members)
+	return base64URL(sum[:]), nil
+}
+
+// jwkPrivateMembers holds the private members of a JWK as defined in RFC 7518 and RFC 8037.
+type jwkPrivateMembers struct {
+	D  string `json:"d"`
+	P  string `json:"p,omitempty"`
+	Q  string `json:"q,omitempty"`
+	Dp string `json:"dp,omitempty"`
+	Dq string `json:"dq,omitempty"`
+	Qi string `json:"qi,omitempty"`
+}
+
+// jwkPrivateKey holds the members of a private JWK, including its certificate chain in x5c.
+type jwkPrivateKey struct {
+	jwkPublicKey
+	jwkPrivateMembers
+
+	X5c []string `json:"x5c,omitempty"`
+}
+
+// newJWKPrivateMembers returns the private JWK members of the given RSA, EC or Ed25519 private key.
+// RSA keys must have exactly two primes, as the members of further primes are not supported.
+func newJWKPrivateMembers(key crypto.Signer) (jwkPrivateMembers, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if len(k.Primes) != 2 { //nolint:mnd // p and q
+			return jwkPrivateMembers{}, fmt.Errorf("RSA keys with %d primes are not supported", len(k.Primes))
+		}
+		k.Precompute()
+		return jwkPrivateMembers{
+			D:  base64URL(k.D.Bytes()),
+			P:  base64URL(k.Primes[0].Bytes()),
+			Q:  base64URL(k.Primes[1].Bytes()),
+			Dp: base64URL(k.Precomputed.Dp.Bytes()),
+			Dq: base64URL(k.Precomputed.Dq.Bytes()),
+			Qi: base64URL(k.Precomputed.Qinv.Bytes()),
+		}, nil
+	case *ecdsa.PrivateKey:
+		d, err := k.Bytes()
+		if err != nil {
+			return jwkPrivateMembers{}, fmt.Errorf("encoding EC private key: %w", err)
+		}
+		return jwkPrivateMembers{D: base64URL(d)}, nil
+	case ed25519.PrivateKey:
+		return jwkPrivateMembers{D: base64URL(k.Seed())}, nil
+	default:
+		return jwkPrivateMembers{}, fmt.Errorf("unsupported private key of type %T", key)
+	}
+}
+
+// parseJWKPrivateKey returns the RSA, EC or Ed25519 private key of the given JWK and the DER encoded certificates of
+// its x5c.
+func parseJWKPrivateKey(data []byte) (crypto.Signer, [][]byte, error) {
+	var jwk jwkPrivateKey
+	if err := json.Unmarshal(data, &jwk); err != nil {
+		return nil, nil, fmt.Errorf("parsing JWK: %w", err)
+	}
+	var certs [][]byte
+	for _, encoded := range jwk.X5c {
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decoding x5c of JWK: %w", err)
+		}
+		certs = append(certs, der)
+	}
+
+	key, err := jwk.signer()
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing JWK: %w", err)
+	}
+	return key, certs, nil
+}
+
+// signer returns the private key of the JWK.
+func (jwk jwkPrivateKey) signer() (crypto.Signer, error) {
+	d, err := base64.RawURLEncoding.DecodeString(jwk.D)
+	if err != nil || len(d) == 0 {
+		return nil, errors.New("the private key member d is missing or invalid")
+	}
+	switch jwk.Kty {
+	case "RSA":
+		return jwk.rsaKey(d)
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		return ecdsa.ParseRawPrivateKey(curve, d)
+	case "OKP":
+		if jwk.Crv != "Ed25519" || len(d) != ed25519.SeedSize {
+			return nil, fmt.Errorf("unsupported OKP key with curve %q", jwk.Crv)
+		}
+		return ed25519.NewKeyFromSeed(d), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+// rsaKey returns the RSA private key of the JWK with the given private exponent. The primes p and q are required.
+func (jwk jwkPrivateKey) rsaKey(d []byte) (*rsa.PrivateKey, error) {
+	var values [4]*big.Int
+	for i, member := range []string{jwk.N, jwk.E, jwk.P, jwk.Q} {
+		b, err := base64.RawURLEncoding.DecodeString(member)
+		if err != nil || len(b) == 0 {
+			return nil, errors.New("the RSA members n, e, p and q are required")
+		}
+		values[i] = new(big.Int).SetBytes(b)
+	}
+	if !values[1].IsInt64() {
+		return nil, errors.New("the RSA public exponent is too large")
+	}
+	key := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: values[0], E: int(values[1].Int64())},
+		D:         new(big.Int).SetBytes(d),
+		Primes:    []*big.Int{values[2], values[3]},
+	}
+	if err := key.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid RSA private key: %w", err)
+	}
+	key.Precompute()
+	return key, nil
+}
@@ -0,0 +1,170 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// jwksKey is the data key of the public JWK set of the slots in the target.
+	jwksKey = "jwks.json"
+	// privateJWKSKey is the data key of the private JWK set of the slots in the target.
+	privateJWKSKey = "jwks-private.json"
+	// activeKidKey is the data key of the kid of the active key in tls.*, i.e. the key to sign with.
+	activeKidKey = "active.kid"
+)
+
+// publicJWK is a public JWK of a slot with the members consumers need to pick and verify the key.
+type publicJWK struct {
+	jwkPublicKey
+
+	Kid     string   `json:"kid"`
+	Alg     string   `json:"alg,omitempty"`
+	Use     string   `json:"use"`
+	X5c     []string `json:"x5c,omitempty"`
+	X5tS256 string   `json:"x5t#S256,omitempty"`
+}
+
+// privateJWK is a private JWK of a slot, i.e. its public JWK with the private members of its key.
+type privateJWK struct {
+	publicJWK
+	jwkPrivateMembers
+}
+
+// jwkSet is a JWK set as defined in RFC 7517.
+type jwkSet[T any] struct {
+	Keys []T `json:"keys"`
+}
+
+// jwksSlotNames returns the names of the slots in the secret data in the order their keys are listed in JWK sets:
+// the active slot first, followed by next-tls and the previous slots from the newest to the oldest.
+func jwksSlotNames(data map[string][]byte) []string {
+	names := []string{activeSlot, nextSlot}
+	for _, name := range existingSlotNames(data) {
+		if name != activeSlot && name != nextSlot {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// newPublicJWK returns the public JWK of the given slot.
+func newPublicJWK(s slot) (publicJWK, error) {
+	cert, err := parseCertificate(s.crt)
+	if err != nil {
+		return publicJWK{}, err
+	}
+	pub, err := newJWKPublicKey(cert.PublicKey)
+	if err != nil {
+		return publicJWK{}, err
+	}
+	var x5c []string
+	if len(s.x5c) > 0 {
+		if err = json.Unmarshal(s.x5c, &x5c); err != nil {
+			return publicJWK{}, fmt.Errorf("parsing x5c of kid %s: %w", s.kid, err)
+		}
+	}
+	thumbprint := sha256.Sum256(cert.Raw)
+	return publicJWK{
+		jwkPublicKey: pub,
+		Kid:          string(s.kid),
+		Alg:          string(s.alg),
+		Use:          "sig",
+		X5c:          x5c,
+		X5tS256:      base64URL(thumbprint[:]),
+	}, nil
+}
+
+// newPrivateJWK returns the private JWK of the given slot.
+func newPrivateJWK(s slot) (privateJWK, error) {
+	pub, err := newPublicJWK(s)
+	if err != nil {
+		return privateJWK{}, err
+	}
+	key, err := parsePrivateKey(s.key)
+	if err != nil {
+		return privateJWK{}, err
+	}
+	members, err := newJWKPrivateMembers(key)
+	if err != nil {
+		return privateJWK{}, err
+	}
+	return privateJWK{publicJWK: pub, jwkPrivateMembers: members}, nil
+}
+
+// renderJWKS returns the JWK set of the slots in the given secret data, with every key rendered by the given function.
+// Empty slots are omitted. The output only depends on the slots, so that unchanged slots result in identical bytes.
+func renderJWKS[T any](data map[string][]byte, render func(slot) (T, error)) ([]byte, error) {
+	set := jwkSet[T]{Keys: []T{}}
+	for _, name := range jwksSlotNames(data) {
+		s := readSlot(data, name)
+		if len(s.kid) == 0 {
+			continue
+		}
+		jwk, err := render(s)
+		if err != nil {
+			return nil, fmt.Errorf("rendering JWK of slot %s: %w", name, err)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	jwks, err := json.Marshal(set)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling JWK set: %w", err)
+	}
+	return jwks, nil
+}
+
+// publicJWKS returns the public JWK set of the slots in the given secret data.
+func publicJWKS(data map[string][]byte) ([]byte, error) {
+	return renderJWKS(data, newPublicJWK)
+}
+
+// privateJWKS returns the private JWK set of the slots in the given secret data.
+func privateJWKS(data map[string][]byte) ([]byte, error) {
+	return renderJWKS(data, newPrivateJWK)
+}
+
+// renderTargetOutputs derives the outputs of the target from its slots, i.e. the public and private JWK sets, the
+// kid of the active key, and the keystores and OpenID Connect metadata configured on the source.
+// Keystores and metadata that cannot be written, e.g. because the password secret is missing or the issuer is
+// invalid, are reported with a warning event and removed from the target instead of failing, so that a broken
+// configuration doesn't block rotations and revocations. Stale outputs are not kept, as they might still refer to
+// rotated out or revoked keys. It does not update the secret in the cluster.
+func (r *SecretReconciler) renderTargetOutputs(
+	ctx context.Context,
+	source *corev1.Secret,
+	target *corev1.Secret,
+) error {
+	jwks, err := publicJWKS(target.Data)
+	if err != nil {
+		return err
+	}
+	private, err := privateJWKS(target.Data)
+	if err != nil {
+		return err
+	}
+	target.Data[jwksKey] = jwks
+	target.Data[privateJWKSKey] = private
+	target.Data[activeKidKey] = nonNil(target.Data[activeSlot+".kid"])
+	if err = r.renderKeystores(ctx, source, target); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to write the keystores into the target secret")
+		r.Recorder.Eventf(source, nil, corev1.EventTypeWarning, "KeystoreFailed", "Rotate",
+			"Failed to write the keystores into target secret %s: %s", target.Name, err.Error())
+		removeKeystores(target)
+	}
+	if err = renderOIDCMetadata(source, target); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to write the OpenID Connect metadata into the target secret")
+		r.Recorder.Eventf(source, nil, corev1.EventTypeWarning, "OIDCMetadataFailed", "Rotate",
+			"Failed to write the OpenID Connect metadata into target secret %s: %s", target.Name, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// jwksServerReadHeaderTimeout limits the time clients have to send the request headers.
+	jwksServerReadHeaderTimeout = 10 * time.Second
+	// jwksServerShutdownTimeout limits the time in-flight requests have to complete on shutdown.
+	jwksServerShutdownTimeout = 5 * time.Second
+	// jwksContentType is the media type of JWK sets defined in RFC 7517.
+	jwksContentType = "application/jwk-set+json"
+	// oidcMetadataContentType is the media type of OpenID Connect discovery documents.
+	oidcMetadataContentType = "application/json"
+)
+
+// JWKSServer serves the public JWK sets of the target secrets over HTTP at /jwks/{namespace}/{target}, and their
+// OpenID Connect discovery documents at /jwks/{namespace}/{target}/.well-known/openid-configuration.
+// The target secrets are read from the given reader, usually the cache of the manager, so that requests don't reach
+// the API server. It implements the Runnable interface of the manager.
+type JWKSServer struct {
+	// BindAddress is the address the server listens on, e.g. :8082.
+	BindAddress string
+	// Reader reads the target secrets.
+	Reader client.Reader
+	// MaxAge is the max-age of the Cache-Control header of the responses. For targets whose source sets a
+	// min-next-age, half of it is used if it is shorter, so that clients pick up next-tls.* before it is promoted.
+	MaxAge time.Duration
+}
+
+// Start serves the JWK sets until the context is done.
+func (s *JWKSServer) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("jwks-server")
+	server := &http.Server{
+		Addr:              s.BindAddress,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: jwksServerReadHeaderTimeout,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Info("Serving JWK sets", "address", s.BindAddress)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("serving JWK sets: %w", err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), jwksServerShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("shutting down JWKS server: %w", err)
+		}
+		return nil
+	}
+}
+
+// NeedLeaderElection returns false, so that the JWK sets are served by every replica of the manager.
+func (s *JWKSServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Handler returns the HTTP handler serving the JWK sets and the OpenID Connect discovery documents. The JWK set is
+// served at /.well-known/jwks.json below the target as well, so that the URL of the target can be used as issuer
+// with the default jwks_uri.
+func (s *JWKSServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jwks/{namespace}/{target}", s.serveTargetData(jwksKey, jwksContentType))
+	mux.HandleFunc("GET /jwks/{namespace}/{target}"+defaultJWKSPath, s.serveTargetData(jwksKey, jwksContentType))
+	mux.HandleFunc("GET /jwks/{namespace}/{target}/.well-known/openid-configuration",
+		s.serveTargetData(oidcMetadataKey, oidcMetadataContentType))
+	return mux
+}
+
+// serveTargetData returns a handler that responds with the given data key of the requested target secret. Only
+// targets managed by the operator, i.e. controlled by their source secret, are served, so that secrets which merely
+// carry the annotations of a target are not exposed. The ETag of the response is derived from the data, so that
+// clients can revalidate their copy.
+func (s *JWKSServer) serveTargetData(key string, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		log := ctrl.Log.WithName("jwks-server")
+		name := types.NamespacedName{Namespace: req.PathValue("namespace"), Name: req.PathValue("target")}
+
+		target := &corev1.Secret{}
+		if err := s.Reader.Get(req.Context(), name, target); err != nil {
+			if apierrors.IsNotFound(err) {
+				http.NotFound(w, req)
+				return
+			}
+			log.Error(err, "Failed to get target secret", "target", name)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		owner := metav1.GetControllerOf(target)
+		data, ok := target.Data[key]
+		if owner == nil || owner.Kind != "Secret" || !ok {
+			http.NotFound(w, req)
+			return
+		}
+
+		sum := sha256.Sum256(data)
+		etag := `"` + base64URL(sum[:]) + `"`
+		w.Header().Set("ETag", etag)
+		maxAge := s.maxAge(req.Context(), target, owner.Name)
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+		if matchesETag(req.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write(data)
+	}
+}
+
+// maxAge returns the max-age of the response for the given target: the configured max-age, or half of the
+// min-next-age of its source if that is shorter.
+func (s *JWKSServer) maxAge(ctx context.Context, target *corev1.Secret, sourceName string) time.Duration {
+	maxAge := s.MaxAge
+	source := &corev1.Secret{}
+	name := types.NamespacedName{Namespace: target.Namespace, Name: sourceName}
+	if err := s.Reader.Get(ctx, name, source); err != nil {
+		return maxAge
+	}
+	if minAge, err := time.ParseDuration(source.Annotations[minNextAgeAnnotation]); err == nil && minAge > 0 {
+		maxAge = min(maxAge, minAge/2) //nolint:mnd // half of the period next-tls.* is published before promotion
+	}
+	return maxAge
+}
+
+// matchesETag returns whether the given If-None-Match header matches the ETag.
+func matchesETag(header string, etag string) bool {
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// keyEncodingAnnotation can be set on a source secret to select the encoding of the private keys it rotates into the
+// target, overriding the encoding configured for the operator.
+const keyEncodingAnnotation = "rotator.gw.ei.telekom.de/key-encoding"
+
+// KeyEncoding selects the encoding private keys are normalized into when they enter the target.
+type KeyEncoding string
+
+const (
+	// KeyEncodingKeep keeps the private key of the source as is. It is the default.
+	KeyEncodingKeep KeyEncoding = "keep"
+	// KeyEncodingPKCS1 encodes RSA keys as PKCS#1 "RSA PRIVATE KEY".
+	KeyEncodingPKCS1 KeyEncoding = "pkcs1"
+	// KeyEncodingPKCS8 encodes keys of all types as PKCS#8 "PRIVATE KEY".
+	KeyEncodingPKCS8 KeyEncoding = "pkcs8"
+	// KeyEncodingSEC1 encodes EC keys as SEC 1 "EC PRIVATE KEY".
+	KeyEncodingSEC1 KeyEncoding = "sec1"
+)
+
+// ParseKeyEncoding returns the key encoding with the given name.
+func ParseKeyEncoding(val string) (KeyEncoding, error) {
+	switch encoding := KeyEncoding(val); encoding {
+	case KeyEncodingKeep, KeyEncodingPKCS1, KeyEncodingPKCS8, KeyEncodingSEC1:
+		return encoding, nil
+	default:
+		return "", fmt.Errorf("key encoding must be one of %s, %s, %s or %s, got %q",
+			KeyEncodingKeep, KeyEncodingPKCS1, KeyEncodingPKCS8, KeyEncodingSEC1, val)
+	}
+}
+
+// parseKeyEncoding returns the key encoding configured on the source. It is empty if the source doesn't override the
+// encoding of the operator.
+func parseKeyEncoding(source *corev1.Secret) (KeyEncoding, error) {
+	val, ok := source.Annotations[keyEncodingAnnotation]
+	if !ok {
+		return "", nil
+	}
+	encoding, err := ParseKeyEncoding(val)
+	if err != nil {
+		return "", fmt.Errorf("parsing %s: %w", keyEncodingAnnotation, err)
+	}
+	return encoding, nil
+}
+
+// normalizeKey returns the PEM encoded private key in the given encoding. It fails if the key cannot be represented in
+// the encoding, e.g. an EC key in PKCS#1.
+func normalizeKey(key []byte, encoding KeyEncoding) ([]byte, error) {
+	if encoding == "" || encoding == KeyEncodingKeep {
+		return key, nil
+	}
+	signer, err := parsePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var blockType string
+	var der []byte
+	switch encoding {
+	case KeyEncodingPKCS1:
+		rsaKey, ok := signer.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key of type %T cannot be encoded as PKCS#1, only RSA keys can", signer)
+		}
+		blockType, der = "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)
+	case KeyEncodingSEC1:
+		ecKey, ok := signer.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key of type %T cannot be encoded as SEC 1, only EC keys can", signer)
+		}
+		blockType = "EC PRIVATE KEY"
+		der, err = x509.MarshalECPrivateKey(ecKey)
+	case KeyEncodingPKCS8:
+		blockType = "PRIVATE KEY"
+		der, err = x509.MarshalPKCS8PrivateKey(signer)
+	default:
+		return nil, fmt.Errorf("unknown key encoding %q", encoding)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encoding private key as %s: %w", encoding, err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), nil
+}
+
+// normalizeIncomingKey normalizes the private key of the incoming slot into the encoding selected by the source or
+// the operator. A key that cannot be converted is refused with a warning event and false is returned.
+func (r *SecretReconciler) normalizeIncomingKey(
+	ctx context.Context,
+	source *corev1.Secret,
+	incoming *slot,
+	opts rotationOptions,
+) bool {
+	log := logf.FromContext(ctx)
+
+	encoding := opts.keyEncoding
+	if encoding == "" {
+		encoding = r.KeyEncoding
+	}
+	key, err := normalizeKey(incoming.key, encoding)
+	if err != nil {
+		log.Error(err, "Refusing to rotate source secret, its private key cannot be converted", "keyEncoding", encoding)
+		r.Recorder.Eventf(source, nil, corev1.EventTypeWarning, "KeyEncodingRejected", "Validate",
+			"Refusing to rotate source, its private key cannot be converted to %s: %s", encoding, err.Error())
+		return false
+	}
+	incoming.key = key
+	return true
+}
@@ -0,0 +1,264 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// keyPolicyConfigMapName is the name of the config map that tightens the key policy of the operator for the
+	// sources in its namespace.
+	keyPolicyConfigMapName = "rotator-key-policy"
+
+	// Keys of the key policy config map.
+	minRSABitsKey    = "min-rsa-bits"
+	allowedCurvesKey = "allowed-curves"
+	rejectSHA1Key    = "reject-sha1"
+	maxLifetimeKey   = "max-lifetime"
+)
+
+// Rules of the key policy, used as label of the violation metric.
+const (
+	ruleRSAKeySize    = "rsa-key-size"
+	ruleCurve         = "curve"
+	ruleSHA1Signature = "sha1-signature"
+	ruleLifetime      = "lifetime"
+)
+
+// KeyPolicy restricts the key material that is rotated into target secrets.
+// The zero value allows any key material.
+type KeyPolicy struct {
+	// MinRSABits is the minimum size of RSA keys. RSA keys of any size are allowed if it is 0.
+	MinRSABits int
+	// AllowedCurves are the names of the allowed EC curves, e.g. P-256, including Ed25519.
+	// All curves are allowed if it is empty.
+	AllowedCurves []string
+	// RejectSHA1 rejects certificates signed with SHA-1.
+	RejectSHA1 bool
+	// MaxLifetime is the maximum validity period of certificates. Certificates of any lifetime are allowed if it is 0.
+	MaxLifetime time.Duration
+}
+
+// policyViolation is a violated rule of the key policy.
+type policyViolation struct {
+	rule    string
+	message string
+}
+
+// newKeyPolicyViolationsMetric returns the counter of key policy violations by namespace, source and rule.
+func newKeyPolicyViolationsMetric() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rotator_key_policy_violations_total",
+		Help: "Number of source secrets refused because of a key policy violation.",
+	}, []string{"namespace", "source", "rule"})
+}
+
+// registerKeyPolicyViolationsMetric registers the counter of key policy violations with the controller-runtime
+// metrics registry. If it has already been registered, e.g. by another reconciler, the registered counter is used.
+func (r *SecretReconciler) registerKeyPolicyViolationsMetric() error {
+	r.policyViolations = newKeyPolicyViolationsMetric()
+	err := metrics.Registry.Register(r.policyViolations)
+	var registered prometheus.AlreadyRegisteredError
+	if errors.As(err, &registered) {
+		existing, ok := registered.ExistingCollector.(*prometheus.CounterVec)
+		if !ok {
+			return err
+		}
+		r.policyViolations = existing
+		return nil
+	}
+	return err
+}
+
+// ParseCurves parses a comma separated list of curves, ignoring whitespace and empty entries.
+func ParseCurves(val string) []string {
+	var curves []string
+	for curve := range strings.SplitSeq(val, ",") {
+		if curve = strings.TrimSpace(curve); curve != "" {
+			curves = append(curves, curve)
+		}
+	}
+	return curves
+}
+
+// withOverrides returns the policy with the values of the given key policy config map taking precedence. Overrides
+// can only tighten the policy, an override that loosens it is rejected with an error.
+func (p KeyPolicy) withOverrides(cm *corev1.ConfigMap) (KeyPolicy, error) {
+	o := p
+	if val, ok := cm.Data[minRSABitsKey]; ok {
+		bits, err := strconv.Atoi(val)
+		if err != nil || bits < 0 {
+			return KeyPolicy{}, fmt.Errorf("%s must be a non-negative integer, got %q", minRSABitsKey, val)
+		}
+		o.MinRSABits = bits
+	}
+	if val, ok := cm.Data[allowedCurvesKey]; ok {
+		o.AllowedCurves = ParseCurves(val)
+	}
+	if val, ok := cm.Data[rejectSHA1Key]; ok {
+		reject, err := strconv.ParseBool(val)
+		if err != nil {
+			return KeyPolicy{}, fmt.Errorf("%s must be a boolean, got %q", rejectSHA1Key, val)
+		}
+		o.RejectSHA1 = reject
+	}
+	if val, ok := cm.Data[maxLifetimeKey]; ok {
+		lifetime, err := time.ParseDuration(val)
+		if err != nil || lifetime < 0 {
+			return KeyPolicy{}, fmt.Errorf("%s must be a non-negative duration, got %q", maxLifetimeKey, val)
+		}
+		o.MaxLifetime = lifetime
+	}
+	if err := p.checkTightenedBy(o); err != nil {
+		return KeyPolicy{}, err
+	}
+	return o, nil
+}
+
+// checkTightenedBy returns an error if the given overridden policy allows key material that the policy refuses.
+func (p KeyPolicy) checkTightenedBy(o KeyPolicy) error {
+	switch {
+	case o.MinRSABits < p.MinRSABits:
+		return fmt.Errorf("%s must not be lower than %d, got %d", minRSABitsKey, p.MinRSABits, o.MinRSABits)
+	case len(p.AllowedCurves) > 0 && (len(o.AllowedCurves) == 0 || slices.ContainsFunc(o.AllowedCurves,
+		func(curve string) bool { return !slices.Contains(p.AllowedCurves, curve) })):
+		return fmt.Errorf("%s must be a subset of %s, got %s", allowedCurvesKey,
+			strings.Join(p.AllowedCurves, ","), strings.Join(o.AllowedCurves, ","))
+	case p.RejectSHA1 && !o.RejectSHA1:
+		return fmt.Errorf("%s must not be disabled", rejectSHA1Key)
+	case p.MaxLifetime > 0 && (o.MaxLifetime == 0 || o.MaxLifetime > p.MaxLifetime):
+		return fmt.Errorf("%s must not be longer than %s, got %s", maxLifetimeKey, p.MaxLifetime, o.MaxLifetime)
+	}
+	return nil
+}
+
+// violations returns the rules of the policy the given certificate violates.
+func (p KeyPolicy) violations(cert *x509.Certificate) []policyViolation {
+	var violations []policyViolation
+	curve := ""
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if bits := key.N.BitLen(); bits < p.MinRSABits {
+			violations = append(violations, policyViolation{ruleRSAKeySize,
+				fmt.Sprintf("RSA key has %d bits, at least %d are required", bits, p.MinRSABits)})
+		}
+	case *ecdsa.PublicKey:
+		curve = key.Curve.Params().Name
+	case ed25519.PublicKey:
+		curve = "Ed25519"
+	}
+	if curve != "" && len(p.AllowedCurves) > 0 && !slices.Contains(p.AllowedCurves, curve) {
+		violations = append(violations, policyViolation{ruleCurve,
+			fmt.Sprintf("curve %s is not allowed, allowed are %s", curve, strings.Join(p.AllowedCurves, ", "))})
+	}
+	sha1 := []x509.SignatureAlgorithm{x509.SHA1WithRSA, x509.ECDSAWithSHA1, x509.DSAWithSHA1}
+	if p.RejectSHA1 && slices.Contains(sha1, cert.SignatureAlgorithm) {
+		violations = append(violations, policyViolation{ruleSHA1Signature,
+			fmt.Sprintf("certificate is signed with %s", cert.SignatureAlgorithm)})
+	}
+	if lifetime := cert.NotAfter.Sub(cert.NotBefore); p.MaxLifetime > 0 && lifetime > p.MaxLifetime {
+		violations = append(violations, policyViolation{ruleLifetime,
+			fmt.Sprintf("certificate is valid for %s, at most %s is allowed", lifetime, p.MaxLifetime)})
+	}
+	return violations
+}
+
+// keyPolicy returns the key policy for the sources in the given namespace, i.e. the policy of the operator with the
+// overrides of the key policy config map in the namespace.
+func (r *SecretReconciler) keyPolicy(ctx context.Context, namespace string) (KeyPolicy, error) {
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: keyPolicyConfigMapName}, cm)
+	if apierrors.IsNotFound(err) {
+		return r.KeyPolicy, nil
+	}
+	if err != nil {
+		return KeyPolicy{}, fmt.Errorf("getting key policy config map: %w", err)
+	}
+	policy, err := r.KeyPolicy.withOverrides(cm)
+	if err != nil {
+		return KeyPolicy{}, fmt.Errorf("parsing key policy config map %s: %w", keyPolicyConfigMapName, err)
+	}
+	return policy, nil
+}
+
+// countedViolations are the violated rules of a source that have been counted for the certificate with the fingerprint.
+type countedViolations struct {
+	fingerprint [sha256.Size]byte
+	rules       []string
+}
+
+// countViolations counts the given violations of the certificate of the source in the violation metric. A violation is
+// only counted once for the same certificate, so that reconciling an unchanged source doesn't inflate the metric.
+func (r *SecretReconciler) countViolations(
+	source *corev1.Secret, cert *x509.Certificate, violations []policyViolation) {
+	key := client.ObjectKeyFromObject(source)
+	if len(violations) == 0 {
+		r.countedViolations.Delete(key)
+		return
+	}
+
+	counted := countedViolations{fingerprint: sha256.Sum256(cert.Raw)}
+	for _, violation := range violations {
+		counted.rules = append(counted.rules, violation.rule)
+	}
+	previous, _ := r.countedViolations.Swap(key, counted)
+	last, _ := previous.(countedViolations)
+	for _, rule := range counted.rules {
+		if last.fingerprint == counted.fingerprint && slices.Contains(last.rules, rule) {
+			continue
+		}
+		if r.policyViolations != nil {
+			r.policyViolations.WithLabelValues(source.Namespace, source.Name, rule).Inc()
+		}
+	}
+}
+
+// checkKeyPolicy checks the given certificate of the source against the key policy of its namespace and returns
+// whether it can be rotated. Every violated rule is reported with a warning event and counted in the violation metric
+// once per certificate. The certificate has to be validated before. An error is only returned if the policy cannot be
+// determined.
+func (r *SecretReconciler) checkKeyPolicy(ctx context.Context, source *corev1.Secret, crt []byte) (bool, error) {
+	log := logf.FromContext(ctx)
+
+	policy, err := r.keyPolicy(ctx, source.Namespace)
+	if err != nil {
+		log.Error(err, "Failed to determine the key policy")
+		return false, err
+	}
+	cert, err := parseCertificate(crt)
+	if err != nil {
+		return false, err
+	}
+
+	violations := policy.violations(cert)
+	for _, violation := range violations {
+		log.Info("Refusing to rotate source secret, it violates the key policy",
+			"rule", violation.rule, "violation", violation.message)
+		r.Recorder.Eventf(source, nil, corev1.EventTypeWarning, "KeyPolicyViolation", "Validate",
+			"Refusing to rotate source, it violates the key policy: %s", violation.message)
+	}
+	r.countViolations(source, cert, violations)
+	return len(violations) == 0, nil
+}
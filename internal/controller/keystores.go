@@ -0,0 +1,200 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"slices"
+	"strings"
+
+	"gw.ei.telekom.de/rotator/internal/keystore"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// keystoresAnnotation can be set on a source secret to write the keys of all slots into keystores in the target,
+	// given as comma separated list of formats, e.g. pkcs12,jks.
+	keystoresAnnotation = "rotator.gw.ei.telekom.de/keystores"
+	// keystorePasswordSecretAnnotation names the secret in the namespace of the source that holds the password of the
+	// keystores written into the target. It is required if keystores are written.
+	keystorePasswordSecretAnnotation = "rotator.gw.ei.telekom.de/keystore-password-secret"
+	// keystorePasswordKeyAnnotation names the data key of the keystore password secret holding the password.
+	keystorePasswordKeyAnnotation = "rotator.gw.ei.telekom.de/keystore-password-key"
+
+	// pkcs12KeystoreKey and jksKeystoreKey are the data keys of the keystores in the target.
+	pkcs12KeystoreKey = "keystore.p12"
+	jksKeystoreKey    = "keystore.jks"
+)
+
+// keystoreFormat is the format of a keystore written into the target.
+type keystoreFormat string
+
+const (
+	keystoreFormatPKCS12 keystoreFormat = "pkcs12"
+	keystoreFormatJKS    keystoreFormat = "jks"
+)
+
+// keystoreKeys are the data keys of the keystores in the target by their format.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var keystoreKeys = map[keystoreFormat]string{
+	keystoreFormatPKCS12: pkcs12KeystoreKey,
+	keystoreFormatJKS:    jksKeystoreKey,
+}
+
+// parseKeystoreFormats returns the formats of the keystores to write into the target, as configured on the source.
+func parseKeystoreFormats(source *corev1.Secret) ([]keystoreFormat, error) {
+	var formats []keystoreFormat
+	for val := range strings.SplitSeq(source.Annotations[keystoresAnnotation], ",") {
+		format := keystoreFormat(strings.TrimSpace(val))
+		if format == "" {
+			continue
+		}
+		if _, ok := keystoreKeys[format]; !ok {
+			return nil, fmt.Errorf("%s must be a list of %s and %s, got %q", keystoresAnnotation,
+				keystoreFormatPKCS12, keystoreFormatJKS, format)
+		}
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+// keystoreEntries returns the keys of the slots in the given secret data as keystore entries with their kids as
+// aliases, in the order of the JWK sets. Empty slots are omitted.
+func keystoreEntries(data map[string][]byte) ([]keystore.Entry, error) {
+	var entries []keystore.Entry
+	for _, name := range jwksSlotNames(data) {
+		s := readSlot(data, name)
+		if len(s.kid) == 0 {
+			continue
+		}
+		key, err := parsePrivateKey(s.key)
+		if err != nil {
+			return nil, fmt.Errorf("parsing key of slot %s: %w", name, err)
+		}
+		certs, err := parseCertificateChain(s.crt)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate of slot %s: %w", name, err)
+		}
+		entries = append(entries, keystore.Entry{Alias: string(s.kid), Key: key, Certs: certs})
+	}
+	return entries, nil
+}
+
+// renderKeystores writes the keys of all slots of the target into the keystores configured on the source, protected
+// by the password from the keystore password secret. Keystores that are not configured are removed from the target.
+//
+// The salts and IVs of the keystores are derived from the slots and the password instead of being random, so that
+// unchanged slots result in identical bytes and the target is only updated if the slots or the password changed.
+// It does not update the secret in the cluster.
+func (r *SecretReconciler) renderKeystores(ctx context.Context, source *corev1.Secret, target *corev1.Secret) error {
+	formats, err := parseKeystoreFormats(source)
+	if err != nil {
+		return err
+	}
+	for format, key := range keystoreKeys {
+		if !slices.Contains(formats, format) {
+			delete(target.Data, key)
+		}
+	}
+	if len(formats) == 0 {
+		return nil
+	}
+
+	name := source.Annotations[keystorePasswordSecretAnnotation]
+	if name == "" {
+		return fmt.Errorf("%s is required to write keystores", keystorePasswordSecretAnnotation)
+	}
+	password, err := r.password(ctx, source.Namespace, name,
+		dataKey(source, keystorePasswordKeyAnnotation, defaultPasswordKey))
+	if err != nil {
+		return err
+	}
+	entries, err := keystoreEntries(target.Data)
+	if err != nil {
+		return err
+	}
+
+	for _, format := range formats {
+		random := newDigestReader(keystoreSeed(format, password, target.Data))
+		var encoded []byte
+		switch format {
+		case keystoreFormatPKCS12:
+			encoded, err = keystore.EncodePKCS12(entries, password, random)
+		case keystoreFormatJKS:
+			encoded, err = keystore.EncodeJKS(entries, password, random)
+		default:
+			err = errors.New("unknown keystore format")
+		}
+		if err != nil {
+			return fmt.Errorf("encoding %s keystore: %w", format, err)
+		}
+		target.Data[keystoreKeys[format]] = encoded
+	}
+	return nil
+}
+
+// removeKeystores removes all keystores from the target. It does not update the secret in the cluster.
+func removeKeystores(target *corev1.Secret) {
+	for _, key := range keystoreKeys {
+		delete(target.Data, key)
+	}
+}
+
+// keystoreSeed returns the seed of the salts and IVs of a keystore in the given format, i.e. a digest of the format,
+// the password and the slots in the given secret data.
+func keystoreSeed(format keystoreFormat, password string, data map[string][]byte) []byte {
+	h := sha256.New()
+	writeField(h, []byte(format))
+	writeField(h, []byte(password))
+	for _, name := range jwksSlotNames(data) {
+		s := readSlot(data, name)
+		writeField(h, s.kid)
+		writeField(h, s.crt)
+		writeField(h, s.key)
+	}
+	return h.Sum(nil)
+}
+
+// writeField writes the given field prefixed with its length into the hash, so that the fields are unambiguous.
+func writeField(h hash.Hash, field []byte) {
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(field))))
+	h.Write(field)
+}
+
+// digestReader is a deterministic source of random bytes, the SHA-256 digests of a seed and a counter.
+type digestReader struct {
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+// newDigestReader returns a digestReader with the given seed.
+func newDigestReader(seed []byte) *digestReader {
+	return &digestReader{seed: seed}
+}
+
+// Read fills p with the next bytes of the stream. It never fails.
+func (d *digestReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(d.buf) == 0 {
+			h := sha256.New()
+			h.Write(d.seed)
+			h.Write(binary.BigEndian.AppendUint64(nil, d.counter))
+			d.buf = h.Sum(nil)
+			d.counter++
+		}
+		copied := copy(p[n:], d.buf)
+		d.buf = d.buf[copied:]
+		n += copied
+	}
+	return n, nil
+}
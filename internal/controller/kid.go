@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// kidStrategyAnnotation can be set on a source secret to select how the kid of its certificate is derived.
+	// The target secret records the strategy used for its newest kid in the same annotation.
+	kidStrategyAnnotation = "rotator.gw.ei.telekom.de/kid-strategy"
+	// kidAnnotation can be set on a source secret to use an explicit kid with the explicit strategy.
+	kidAnnotation = "rotator.gw.ei.telekom.de/kid"
+)
+
+// kidStrategy selects how the kid of a certificate is derived.
+type kidStrategy string
+
+const (
+	// kidStrategyUUID derives a UUID from the PEM encoded certificate. It is the default.
+	kidStrategyUUID kidStrategy = "uuid"
+	// kidStrategyThumbprint uses the RFC 7638 JWK thumbprint of the public key, so the kid is stable when a
+	// certificate is renewed with the same key.
+	kidStrategyThumbprint kidStrategy = "jwk-thumbprint"
+	// kidStrategyX5tS256 uses the base64url encoded SHA-256 hash of the DER encoded certificate, i.e. its x5t#S256.
+	kidStrategyX5tS256 kidStrategy = "x5t-s256"
+	// kidStrategySerial uses the hex encoded serial number of the certificate.
+	kidStrategySerial kidStrategy = "serial"
+	// kidStrategyExplicit uses the kid given in the kid annotation of the source.
+	kidStrategyExplicit kidStrategy = "explicit"
+)
+
+// parseKidStrategy returns the kid strategy configured on the source.
+func parseKidStrategy(source *corev1.Secret) (kidStrategy, error) {
+	val, ok := source.Annotations[kidStrategyAnnotation]
+	if !ok {
+		return kidStrategyUUID, nil
+	}
+	switch strategy := kidStrategy(val); strategy {
+	case kidStrategyUUID, kidStrategyThumbprint, kidStrategyX5tS256, kidStrategySerial, kidStrategyExplicit:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("%s must be one of %s, %s, %s, %s or %s, got %q", kidStrategyAnnotation,
+			kidStrategyUUID, kidStrategyThumbprint, kidStrategyX5tS256, kidStrategySerial, kidStrategyExplicit, val)
+	}
+}
+
+// deriveKid returns the kid of the given certificate of the source, derived with the given strategy.
+// The certificate has to be validated before.
+func deriveKid(source *corev1.Secret, crt []byte, strategy kidStrategy) (string, error) {
+	if strategy == kidStrategyUUID {
+		return generateKid(crt).String(), nil
+	}
+	if strategy == kidStrategyExplicit {
+		kid := source.Annotations[kidAnnotation]
+		if kid == "" || strings.ContainsAny(kid, ", \t\n") {
+			return "", errors.New(kidAnnotation + " must be set to a kid without commas or whitespace")
+		}
+		return kid, nil
+	}
+
+	cert, err := parseCertificate(crt)
+	if err != nil {
+		return "", err
+	}
+	switch strategy {
+	case kidStrategyThumbprint:
+		return jwkThumbprint(cert.PublicKey)
+	case kidStrategyX5tS256:
+		sum := sha256.Sum256(cert.Raw)
+		return base64URL(sum[:]), nil
+	case kidStrategySerial:
+		return cert.SerialNumber.Text(16), nil //nolint:mnd // hex encoding
+	default:
+		return "", fmt.Errorf("unknown kid strategy %q", strategy)
+	}
+}
+
+// recordKidStrategy records the given kid strategy on the target. It does not update the secret in the cluster.
+func recordKidStrategy(target *corev1.Secret, strategy kidStrategy) {
+	metav1.SetMetaDataAnnotation(&target.ObjectMeta, kidStrategyAnnotation, string(strategy))
+}
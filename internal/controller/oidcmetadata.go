@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// oidcIssuerAnnotation can be set on a source secret to generate the OpenID Connect discovery document of the
+	// given issuer next to the JWK set of the target.
+	oidcIssuerAnnotation = "rotator.gw.ei.telekom.de/oidc-issuer"
+	// oidcJWKSURIAnnotation can be set on a source secret to set the jwks_uri of the metadata. It defaults to the
+	// issuer followed by /.well-known/jwks.json.
+	oidcJWKSURIAnnotation = "rotator.gw.ei.telekom.de/oidc-jwks-uri"
+	// oidcResponseTypesAnnotation can be set on a source secret to set the comma separated response_types_supported
+	// of the metadata. It defaults to id_token.
+	oidcResponseTypesAnnotation = "rotator.gw.ei.telekom.de/oidc-response-types"
+	// oidcSubjectTypesAnnotation can be set on a source secret to set the comma separated subject_types_supported of
+	// the metadata. It defaults to public.
+	oidcSubjectTypesAnnotation = "rotator.gw.ei.telekom.de/oidc-subject-types"
+
+	// oidcMetadataKey is the data key of the metadata in the target and the public JWK set config map.
+	oidcMetadataKey = "openid-configuration"
+	// defaultJWKSPath is appended to the issuer if the source doesn't set the jwks_uri.
+	defaultJWKSPath = "/.well-known/jwks.json"
+	// defaultResponseType is the response type of issuers that only issue ID tokens signed with the slots.
+	defaultResponseType = "id_token"
+	// defaultSubjectType is the subject type of issuers that don't use pairwise subject identifiers.
+	defaultSubjectType = "public"
+)
+
+// oidcMetadata is the OpenID Provider Metadata served as /.well-known/openid-configuration of the issuer. It contains
+// the fields required by OpenID Connect Discovery for issuers that only publish static metadata next to their keys.
+type oidcMetadata struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// parseHTTPSURL validates the URL of the given annotation. OpenID Connect requires https URLs, issuers must not have
+// a query or fragment either.
+func parseHTTPSURL(annotation string, val string, issuer bool) (string, error) {
+	u, err := url.Parse(val)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("%s must be an https URL, got %q", annotation, val)
+	}
+	if issuer && (u.RawQuery != "" || u.Fragment != "") {
+		return "", fmt.Errorf("%s must not have a query or fragment, got %q", annotation, val)
+	}
+	return val, nil
+}
+
+// parseOIDCList returns the comma separated values of the given annotation of the source, or the default if the
+// source doesn't set it.
+func parseOIDCList(source *corev1.Secret, annotation string, def string) ([]string, error) {
+	val, ok := source.Annotations[annotation]
+	if !ok {
+		return []string{def}, nil
+	}
+	var values []string
+	for value := range strings.SplitSeq(val, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%s must not be empty", annotation)
+	}
+	return values, nil
+}
+
+// newOIDCMetadata returns the metadata configured on the source, with the algorithms of the slots in the given secret
+// data. It returns false if the source doesn't configure an issuer.
+func newOIDCMetadata(source *corev1.Secret, data map[string][]byte) ([]byte, bool, error) {
+	val, ok := source.Annotations[oidcIssuerAnnotation]
+	if !ok {
+		return nil, false, nil
+	}
+	issuer, err := parseHTTPSURL(oidcIssuerAnnotation, val, true)
+	if err != nil {
+		return nil, false, err
+	}
+	jwksURI := strings.TrimSuffix(issuer, "/") + defaultJWKSPath
+	if val, ok = source.Annotations[oidcJWKSURIAnnotation]; ok {
+		if jwksURI, err = parseHTTPSURL(oidcJWKSURIAnnotation, val, false); err != nil {
+			return nil, false, err
+		}
+	}
+	responseTypes, err := parseOIDCList(source, oidcResponseTypesAnnotation, defaultResponseType)
+	if err != nil {
+		return nil, false, err
+	}
+	subjectTypes, err := parseOIDCList(source, oidcSubjectTypesAnnotation, defaultSubjectType)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The algorithms are sorted, so that the metadata only changes if the set of algorithms changes
+	algs := []string{}
+	for _, name := range existingSlotNames(data) {
+		if alg := string(readSlot(data, name).alg); alg != "" && !slices.Contains(algs, alg) {
+			algs = append(algs, alg)
+		}
+	}
+	slices.Sort(algs)
+
+	metadata, err := json.Marshal(oidcMetadata{
+		Issuer:                           issuer,
+		JWKSURI:                          jwksURI,
+		ResponseTypesSupported:           responseTypes,
+		SubjectTypesSupported:            subjectTypes,
+		IDTokenSigningAlgValuesSupported: algs,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("marshaling OpenID Connect metadata: %w", err)
+	}
+	return metadata, true, nil
+}
+
+// renderOIDCMetadata writes the metadata configured on the source into the target, or removes it if the source
+// doesn't configure an issuer or its configuration is invalid. It does not update the secret in the cluster.
+func renderOIDCMetadata(source *corev1.Secret, target *corev1.Secret) error {
+	metadata, ok, err := newOIDCMetadata(source, target.Data)
+	if err != nil || !ok {
+		delete(target.Data, oidcMetadataKey)
+		return err
+	}
+	target.Data[oidcMetadataKey] = metadata
+	return nil
+}
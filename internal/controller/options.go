@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// rotationOptions holds the rotation settings configured by annotations on the source secret.
+type rotationOptions struct {
+	// retainedKeys is the number of previous keys kept in the target.
+	retainedKeys int
+	// historySize is the number of rotations that can be rolled back.
+	historySize int
+	// schedule limits rotations to certain points in time. It is nil if rotations are always allowed.
+	schedule rotationSchedule
+	// kidStrategy selects how the kid of the source certificate is derived.
+	kidStrategy kidStrategy
+	// expiryWarning is the period before the expiry of the active key in which it is flagged as expiring.
+	expiryWarning time.Duration
+	// keyEncoding selects the encoding of the private key rotated into the target. It is empty if the encoding of
+	// the operator is used.
+	keyEncoding KeyEncoding
+}
+
+// parseRotationOptions reads the rotation settings from the annotations of the source secret.
+func parseRotationOptions(source *corev1.Secret) (rotationOptions, error) {
+	retained, err := retainedKeys(source)
+	if err != nil {
+		return rotationOptions{}, err
+	}
+	size, err := historySize(source)
+	if err != nil {
+		return rotationOptions{}, err
+	}
+	schedule, err := parseSchedule(source)
+	if err != nil {
+		return rotationOptions{}, err
+	}
+	strategy, err := parseKidStrategy(source)
+	if err != nil {
+		return rotationOptions{}, err
+	}
+	warning, err := expiryWarning(source)
+	if err != nil {
+		return rotationOptions{}, err
+	}
+	encoding, err := parseKeyEncoding(source)
+	if err != nil {
+		return rotationOptions{}, err
+	}
+	return rotationOptions{
+		retainedKeys:  retained,
+		historySize:   size,
+		schedule:      schedule,
+		kidStrategy:   strategy,
+		expiryWarning: warning,
+		keyEncoding:   encoding,
+	}, nil
+}
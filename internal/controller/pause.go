@@ -0,0 +1,27 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// pausedAnnotation can be set to "true" on a source or target secret to freeze the rotation of the target.
+// Source changes are held back while the annotation is set and applied once it is removed.
+const pausedAnnotation = "rotator.gw.ei.telekom.de/paused"
+
+// isPaused returns whether rotation has been paused on the given secret.
+func isPaused(secret *corev1.Secret) bool {
+	return secret.Annotations[pausedAnnotation] == "true"
+}
+
+// hasPendingChange returns whether the source holds a change that has not been applied to the target yet,
+// i.e. a new certificate, given by crt, or an unhandled rotate or rollback request.
+func hasPendingChange(source *corev1.Secret, target *corev1.Secret, crt []byte) bool {
+	_, rotateRequested := pendingRotateRequest(source, target)
+	_, rollbackRequested := pendingRollbackRequest(source, target)
+	_, sourceInTarget := slotOfCert(target.Data, crt)
+	return rotateRequested || rollbackRequested || !sourceInTarget
+}
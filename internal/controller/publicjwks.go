@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"maps"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// jwksConfigMapAnnotation can be set on a source secret to name the config map in its namespace the public JWK set of
+// the target is published in, so that resource servers can read it without access to secrets.
+const jwksConfigMapAnnotation = "rotator.gw.ei.telekom.de/jwks-configmap-name"
+
+// publishPublicJWKS writes the public JWK set of the target, and its OpenID Connect metadata if the source
+// configures an issuer, into the config map named by the source, if any.
+// The config map is created if it doesn't exist and is owned by the source, so that changes to it trigger a
+// reconciliation that repairs them. Other data of the config map is left untouched. Config maps the public JWK set
+// has been published in before the source named another one are released.
+func (r *SecretReconciler) publishPublicJWKS(ctx context.Context, source *corev1.Secret, target *corev1.Secret) error {
+	name := source.Annotations[jwksConfigMapAnnotation]
+	if err := r.releaseFormerPublicJWKS(ctx, source, name); err != nil {
+		return err
+	}
+	if name == "" {
+		return nil
+	}
+	log := logf.FromContext(ctx).WithValues("configMap", name)
+
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: source.Namespace, Name: name}, cm)
+	exists := true
+	if errors.IsNotFound(err) {
+		exists = false
+		cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: source.Namespace}}
+	} else if err != nil {
+		log.Error(err, "Failed to get public JWK set config map")
+		return err
+	}
+
+	updated := maps.Clone(cm.Data)
+	if updated == nil {
+		updated = map[string]string{}
+	}
+	updated[jwksKey] = string(target.Data[jwksKey])
+	if metadata, ok := target.Data[oidcMetadataKey]; ok {
+		updated[oidcMetadataKey] = string(metadata)
+	} else {
+		delete(updated, oidcMetadataKey)
+	}
+	if exists && maps.Equal(cm.Data, updated) && metav1.IsControlledBy(cm, source) {
+		return nil
+	}
+	cm.Data = updated
+	if err = controllerutil.SetControllerReference(source, cm, r.Scheme); err != nil {
+		log.Error(err, "Failed to set controller reference on public JWK set config map")
+		return err
+	}
+
+	if exists {
+		err = r.update(ctx, cm)
+	} else {
+		err = r.create(ctx, cm)
+	}
+	if err != nil {
+		log.Error(err, "Failed to publish public JWK set config map")
+		return err
+	}
+	log.Info("Successfully published public JWK set config map")
+	return nil
+}
+
+// releaseFormerPublicJWKS removes the public JWK set from the config maps controlled by the source other than the one
+// with the given name, i.e. the config maps it has been published in before. A config map without other data is
+// deleted, otherwise only the data of the operator and the owner reference are removed.
+func (r *SecretReconciler) releaseFormerPublicJWKS(ctx context.Context, source *corev1.Secret, name string) error {
+	log := logf.FromContext(ctx)
+
+	cms := &corev1.ConfigMapList{}
+	if err := r.List(ctx, cms, client.InNamespace(source.Namespace)); err != nil {
+		log.Error(err, "Failed to list config maps")
+		return err
+	}
+	for i := range cms.Items {
+		cm := &cms.Items[i]
+		if cm.Name == name || !metav1.IsControlledBy(cm, source) {
+			continue
+		}
+		delete(cm.Data, jwksKey)
+		delete(cm.Data, oidcMetadataKey)
+		var err error
+		if len(cm.Data) == 0 && len(cm.BinaryData) == 0 {
+			err = r.delete(ctx, cm)
+		} else if err = controllerutil.RemoveOwnerReference(source, cm, r.Scheme); err == nil {
+			err = r.update(ctx, cm)
+		}
+		if err != nil {
+			log.Error(err, "Failed to release former public JWK set config map", "configMap", cm.Name)
+			return err
+		}
+		log.Info("Released former public JWK set config map", "configMap", cm.Name)
+	}
+	return nil
+}
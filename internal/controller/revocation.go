@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// revokedKidsAnnotation can be set on a target secret with a comma separated list of kids that must not be
+// served anymore, e.g. because their private key has been leaked.
+const revokedKidsAnnotation = "rotator.gw.ei.telekom.de/revoked-kids"
+
+// revokedKids returns the kids listed in the revoked-kids annotation of the target.
+func revokedKids(target *corev1.Secret) []string {
+	return splitKids(target.Annotations[revokedKidsAnnotation])
+}
+
+// splitKids splits a comma separated list of kids, ignoring whitespace and empty entries.
+func splitKids(val string) []string {
+	var kids []string
+	for kid := range strings.SplitSeq(val, ",") {
+		if kid = strings.TrimSpace(kid); kid != "" {
+			kids = append(kids, kid)
+		}
+	}
+	return kids
+}
+
+// isRevoked returns whether the given kid has been revoked in the target.
+func isRevoked(target *corev1.Secret, kid string) bool {
+	return slices.Contains(revokedKids(target), kid)
+}
+
+// purgeRevokedSlots empties every slot of the target that holds a revoked kid and returns the names of the
+// emptied slots. It does not update the secret in the cluster.
+func purgeRevokedSlots(target *corev1.Secret) []string {
+	var purged []string
+	for _, name := range existingSlotNames(target.Data) {
+		if kid := string(readSlot(target.Data, name).kid); kid != "" && isRevoked(target, kid) {
+			writeSlot(target.Data, name, slot{})
+			purged = append(purged, name)
+		}
+	}
+	return purged
+}
+
+// revokeKeys removes the revoked keys from the existing target and updates it in the cluster, together with the
+// outputs derived from the slots. It runs before the source is read and validated, so that revoked keys are removed
+// even while the source is invalid or rotation is paused. The public JWK set config map is published afterwards.
+func (r *SecretReconciler) revokeKeys(
+	ctx context.Context,
+	source *corev1.Secret,
+	target *corev1.Secret,
+	now time.Time,
+) error {
+	log := logf.FromContext(ctx)
+
+	original := maps.Clone(target.Data)
+	purged := purgeRevokedSlots(target)
+	if len(purged) == 0 {
+		return nil
+	}
+	recordSlotChanges(target, original, now)
+	if err := r.renderTargetOutputs(ctx, source, target); err != nil {
+		log.Error(err, "Failed to render the outputs of the target secret")
+		return err
+	}
+
+	r.logSlotDiff(ctx, original, target.Data)
+	if err := r.update(ctx, target); err != nil {
+		log.Error(err, "Failed to remove revoked keys from target secret")
+		return err
+	}
+	log.Info("Removed revoked keys from target secret", "slots", purged)
+	r.Recorder.Eventf(target, source, corev1.EventTypeWarning, "KeyRevoked", "Revoke",
+		"Removed revoked keys from slots %s", strings.Join(purged, ", "))
+	return nil
+}
@@ -0,0 +1,367 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// rotationCronAnnotation can be set on a source secret to only rotate during the minutes matched by the given
+	// cron expression, e.g. "* 2-3 * * Mon-Fri" for 02:00 to 04:00 on working days. Times are evaluated in UTC.
+	rotationCronAnnotation = "rotator.gw.ei.telekom.de/rotation-cron"
+	// rotationWindowsAnnotation can be set on a source secret to only rotate during the given comma separated time
+	// windows, e.g. "Mon-Fri 02:00-04:00, Sun 22:00-02:00". Times are evaluated in UTC.
+	rotationWindowsAnnotation = "rotator.gw.ei.telekom.de/rotation-windows"
+
+	// maxScheduleLookahead limits the search for the next window start, e.g. for cron expressions that never match.
+	maxScheduleLookahead = 5 * 366 * 24 * time.Hour
+	daysPerWeek          = 7
+)
+
+// Fields of a cron expression.
+const (
+	cronMinute = iota
+	cronHour
+	cronDay
+	cronMonth
+	cronWeekday
+	cronFields
+)
+
+// rotationSchedule limits rotations to certain points in time, e.g. maintenance windows.
+type rotationSchedule interface {
+	// contains returns whether rotations are allowed at the given time.
+	contains(t time.Time) bool
+	// nextStart returns the earliest time after t at which rotations are allowed.
+	// It returns false if rotations are never allowed again.
+	nextStart(t time.Time) (time.Time, bool)
+}
+
+// parseSchedule reads the rotation schedule from the annotations of the source secret.
+// It returns nil if rotations are allowed at any time.
+func parseSchedule(source *corev1.Secret) (rotationSchedule, error) {
+	cronVal, hasCron := source.Annotations[rotationCronAnnotation]
+	windowsVal, hasWindows := source.Annotations[rotationWindowsAnnotation]
+	switch {
+	case hasCron && hasWindows:
+		return nil, fmt.Errorf("only one of %s and %s can be set", rotationCronAnnotation, rotationWindowsAnnotation)
+	case hasCron:
+		schedule, err := parseCron(cronVal)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", rotationCronAnnotation, err)
+		}
+		return schedule, nil
+	case hasWindows:
+		schedule, err := parseWindows(windowsVal)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", rotationWindowsAnnotation, err)
+		}
+		return schedule, nil
+	default:
+		return nil, nil //nolint:nilnil // no schedule means rotations are always allowed
+	}
+}
+
+// inSchedule returns whether rotations are allowed at the given time. A nil schedule always allows rotations.
+func inSchedule(schedule rotationSchedule, t time.Time) bool {
+	return schedule == nil || schedule.contains(t)
+}
+
+// cronSchedule allows rotations during every minute matched by a cron expression.
+type cronSchedule struct {
+	// fields holds a bit set of the matched values for each field of the cron expression.
+	fields [cronFields]uint64
+	// anyDay is set if the day of month or day of week field is "*". Otherwise, a day matches if either field
+	// matches, like in the classic cron implementation.
+	anyDay bool
+}
+
+// cronField describes the allowed values of a field of a cron expression.
+// Names are matched case-insensitively, the first name standing for the value nameOffset.
+type cronField struct {
+	name       string
+	lo         int
+	hi         int
+	names      []string
+	nameOffset int
+}
+
+// parseCron parses a cron expression with the five fields minute, hour, day of month, month and day of week.
+// Fields support "*", values, ranges, lists and steps, e.g. "*/15", "1-5" or "mon,wed".
+func parseCron(expr string) (*cronSchedule, error) {
+	specs := [cronFields]cronField{
+		cronMinute: {name: "minute", lo: 0, hi: 59},
+		cronHour:   {name: "hour", lo: 0, hi: 23},
+		cronDay:    {name: "day of month", lo: 1, hi: 31},
+		cronMonth: {name: "month", lo: 1, hi: 12, nameOffset: 1, names: []string{
+			"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec",
+		}},
+		// Sunday can be given as 0 or 7
+		cronWeekday: {name: "day of week", lo: 0, hi: daysPerWeek, names: weekdayNames()},
+	}
+
+	values := strings.Fields(expr)
+	if len(values) != cronFields {
+		return nil, fmt.Errorf("expected %d fields, got %d", cronFields, len(values))
+	}
+	var s cronSchedule
+	for i, spec := range specs {
+		set, err := parseCronField(values[i], spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", spec.name, err)
+		}
+		s.fields[i] = set
+	}
+	if s.fields[cronWeekday]&(1<<daysPerWeek) != 0 {
+		s.fields[cronWeekday] |= 1
+	}
+	s.anyDay = values[cronDay] == "*" || values[cronWeekday] == "*"
+	return &s, nil
+}
+
+// weekdayNames returns the abbreviated weekday names accepted in schedules, indexed by time.Weekday.
+func weekdayNames() []string {
+	return []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+}
+
+// parseCronField parses a single field of a cron expression into a bit set of the matched values.
+func parseCronField(val string, spec cronField) (uint64, error) {
+	var set uint64
+	for part := range strings.SplitSeq(val, ",") {
+		rangeVal, stepVal, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepVal); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepVal)
+			}
+		}
+		first, last, err := parseCronRange(rangeVal, hasStep, spec)
+		if err != nil {
+			return 0, err
+		}
+		for v := first; v <= last; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// parseCronRange parses "*", a single value or a range of values of a cron expression field and returns the first
+// and last value. A single value with a step ranges up to the highest allowed value, e.g. "5/15".
+func parseCronRange(val string, hasStep bool, spec cronField) (int, int, error) {
+	if val == "*" {
+		return spec.lo, spec.hi, nil
+	}
+	firstVal, lastVal, isRange := strings.Cut(val, "-")
+	first, err := parseCronValue(firstVal, spec)
+	if err != nil {
+		return 0, 0, err
+	}
+	switch {
+	case isRange:
+		var last int
+		if last, err = parseCronValue(lastVal, spec); err != nil {
+			return 0, 0, err
+		}
+		if first > last {
+			return 0, 0, fmt.Errorf("invalid range %q", val)
+		}
+		return first, last, nil
+	case hasStep:
+		return first, spec.hi, nil
+	default:
+		return first, first, nil
+	}
+}
+
+// parseCronValue parses a single value or name of a cron expression field.
+func parseCronValue(val string, spec cronField) (int, error) {
+	for i, name := range spec.names {
+		if strings.EqualFold(val, name) {
+			return i + spec.nameOffset, nil
+		}
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < spec.lo || n > spec.hi {
+		return 0, fmt.Errorf("invalid value %q, must be between %d and %d", val, spec.lo, spec.hi)
+	}
+	return n, nil
+}
+
+func (s *cronSchedule) contains(t time.Time) bool {
+	t = t.UTC()
+	return s.matchesDay(t) && s.fields[cronHour]&(1<<t.Hour()) != 0 && s.fields[cronMinute]&(1<<t.Minute()) != 0
+}
+
+// matchesDay returns whether the month, day of month and day of week of the given time are matched.
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	if s.fields[cronMonth]&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	day := s.fields[cronDay]&(1<<t.Day()) != 0
+	weekday := s.fields[cronWeekday]&(1<<int(t.Weekday())) != 0
+	if s.anyDay {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// nextStart returns the next matched minute after t. As every minute up to it is not matched, it is the start of
+// the next window. Non-matching days and hours are skipped as a whole.
+func (s *cronSchedule) nextStart(t time.Time) (time.Time, bool) {
+	t = t.UTC()
+	limit := t.Add(maxScheduleLookahead)
+	for c := t.Truncate(time.Minute).Add(time.Minute); c.Before(limit); {
+		switch {
+		case !s.matchesDay(c):
+			c = time.Date(c.Year(), c.Month(), c.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.fields[cronHour]&(1<<c.Hour()) == 0:
+			c = c.Truncate(time.Hour).Add(time.Hour)
+		case s.fields[cronMinute]&(1<<c.Minute()) == 0:
+			c = c.Add(time.Minute)
+		default:
+			return c, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// timeWindow allows rotations on the given weekdays between start and end, given as offsets from midnight.
+// If end is before start, the window spans midnight and ends on the following day.
+type timeWindow struct {
+	weekdays [daysPerWeek]bool
+	start    time.Duration
+	end      time.Duration
+}
+
+// windowSchedule allows rotations during any of its time windows.
+type windowSchedule []timeWindow
+
+// parseWindows parses a comma separated list of time windows. Each window consists of an optional weekday or
+// weekday range and a time range, e.g. "Mon-Fri 02:00-04:00" or "22:00-02:00".
+func parseWindows(val string) (windowSchedule, error) {
+	var schedule windowSchedule
+	for part := range strings.SplitSeq(val, ",") {
+		window, err := parseWindow(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		schedule = append(schedule, window)
+	}
+	return schedule, nil
+}
+
+// parseWindow parses a single time window, see parseWindows.
+func parseWindow(val string) (timeWindow, error) {
+	var w timeWindow
+	timeRange := val
+	if weekdaysVal, rest, ok := strings.Cut(val, " "); ok {
+		first, last, err := parseWeekdayRange(weekdaysVal)
+		if err != nil {
+			return w, fmt.Errorf("window %q: %w", val, err)
+		}
+		// Ranges can wrap around the end of the week, e.g. Sat-Mon
+		for d := first; ; d = (d + 1) % daysPerWeek {
+			w.weekdays[d] = true
+			if d == last {
+				break
+			}
+		}
+		timeRange = strings.TrimSpace(rest)
+	} else {
+		for d := range w.weekdays {
+			w.weekdays[d] = true
+		}
+	}
+
+	startVal, endVal, ok := strings.Cut(timeRange, "-")
+	if !ok {
+		return w, fmt.Errorf("window %q: invalid time range", val)
+	}
+	var err error
+	if w.start, err = parseTimeOfDay(startVal); err != nil {
+		return w, fmt.Errorf("window %q: %w", val, err)
+	}
+	if w.end, err = parseTimeOfDay(endVal); err != nil {
+		return w, fmt.Errorf("window %q: %w", val, err)
+	}
+	if w.start == w.end {
+		return w, fmt.Errorf("window %q: start and end must differ", val)
+	}
+	return w, nil
+}
+
+// parseWeekdayRange parses a weekday or a range of weekdays, e.g. "Mon" or "Mon-Fri".
+func parseWeekdayRange(val string) (int, int, error) {
+	spec := cronField{lo: 0, hi: daysPerWeek - 1, names: weekdayNames()}
+	firstVal, lastVal, isRange := strings.Cut(val, "-")
+	if !isRange {
+		lastVal = firstVal
+	}
+	first, err := parseCronValue(firstVal, spec)
+	if err != nil {
+		return 0, 0, err
+	}
+	last, err := parseCronValue(lastVal, spec)
+	if err != nil {
+		return 0, 0, err
+	}
+	return first, last, nil
+}
+
+// parseTimeOfDay parses a time of day in the format HH:MM and returns its offset from midnight.
+func parseTimeOfDay(val string) (time.Duration, error) {
+	t, err := time.Parse("15:04", val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", val)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (s windowSchedule) contains(t time.Time) bool {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	offset := t.Sub(midnight)
+	weekday := int(t.Weekday())
+	yesterday := (weekday + daysPerWeek - 1) % daysPerWeek
+	for _, w := range s {
+		if w.start < w.end && w.weekdays[weekday] && offset >= w.start && offset < w.end {
+			return true
+		}
+		// Windows spanning midnight belong to the weekday they start on
+		spansMidnight := w.start > w.end
+		if spansMidnight && ((w.weekdays[weekday] && offset >= w.start) || (w.weekdays[yesterday] && offset < w.end)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s windowSchedule) nextStart(t time.Time) (time.Time, bool) {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	var next time.Time
+	for _, w := range s {
+		// Every window starts at least once within the next week
+		for i := range daysPerWeek + 1 {
+			day := midnight.AddDate(0, 0, i)
+			start := day.Add(w.start)
+			if w.weekdays[day.Weekday()] && start.After(t) {
+				if next.IsZero() || start.Before(next) {
+					next = start
+				}
+				break
+			}
+		}
+	}
+	return next, !next.IsZero()
+}
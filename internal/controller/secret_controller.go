@@ -5,33 +5,77 @@
 package controller
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// minNextAgeAnnotation can be set on a source secret to hold back rotations until the current
+	// next-tls.* values have been published in the target for at least the given duration.
+	minNextAgeAnnotation = "rotator.gw.ei.telekom.de/min-next-age"
+	// rotateRequestAnnotation can be set on a source secret to force a rotation step. Every new value of the
+	// annotation triggers exactly one rotation, even if the source certificate did not change.
+	rotateRequestAnnotation = "rotator.gw.ei.telekom.de/rotate-request"
+	// lastRotateRequestAnnotation is set on the target secret and records the last handled rotate request.
+	lastRotateRequestAnnotation = "rotator.gw.ei.telekom.de/last-rotate-request"
+	// eventRecorderName is the name of the event recorder used if the reconciler has none.
+	eventRecorderName = "secret-rotator"
 )
 
 // SecretReconciler reconciles secrets with the proper source annotation.
 type SecretReconciler struct {
 	client.Client
-	Scheme               *runtime.Scheme
+	Scheme *runtime.Scheme
+	// Recorder records the events of the reconciler. It defaults to the event recorder of the manager.
+	Recorder             events.EventRecorder
 	SourceAnnotation     string
 	TargetNameAnnotation string
 	Finalizer            string
+	// Clock is used to evaluate rotation schedules and durations, it can be replaced in tests. It defaults to the
+	// real clock.
+	Clock clock.PassiveClock
+	// KeyEncoding is the encoding private keys are normalized into when they enter the target, unless the source
+	// selects another one. Keys are kept as is if it is empty.
+	KeyEncoding KeyEncoding
+	// KeyPolicy restricts the key material rotated into the target secrets. It can be tightened per namespace.
+	KeyPolicy KeyPolicy
+	// DryRun runs the full reconciliation without creating or updating any secrets or recording events.
+	// Planned changes of the target secrets are logged instead.
+	DryRun bool
+
+	// policyViolations counts the key policy violations of the sources.
+	policyViolations *prometheus.CounterVec
+	// countedViolations holds the last counted key policy violations by source.
+	countedViolations sync.Map
 }
 
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=secrets/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=secrets/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -43,158 +87,581 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	if err := r.Get(ctx, req.NamespacedName, source); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
-
-	// Check if tls.crt and tls.key are set in the source secret
-	if len(source.Data["tls.crt"]) == 0 ||
-		len(source.Data["tls.key"]) == 0 {
-		log.Error(nil, "Source secret does not contain tls.crt and tls.key")
+	// Owners of owned secrets and config maps are enqueued as well, secrets that are no source and have never been
+	// one are ignored
+	isSource := r.isSource(source)
+	if !isSource && !controllerutil.ContainsFinalizer(source, r.Finalizer) {
 		return ctrl.Result{}, nil
 	}
 
 	// Get and update target
+	target, targetExists, err := r.getTarget(ctx, source)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	log = log.WithValues("target", client.ObjectKeyFromObject(target))
+
+	if !source.ObjectMeta.DeletionTimestamp.IsZero() || !isSource {
+		// Source is being deleted or no longer a source, e.g. because its source annotation has been removed
+		return handleDeletion(ctx, r, source, target, targetExists)
+	}
+	if err = r.addFinalizer(ctx, source); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Revoked keys are removed before the source is validated, so that an invalid source doesn't keep them published
+	now := r.now()
+	if targetExists {
+		if err = r.maintainTarget(ctx, source, target, now); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Read the certificate and key of the source secret, by default tls.crt and tls.key, after the deletion is
+	// handled, so that sources with unreadable key material can still be deleted
+	material, ok := r.loadKeyMaterial(ctx, source)
+	if !ok {
+		return ctrl.Result{}, nil
+	}
+	valid, err := r.checkSource(ctx, source, material, now)
+	if err != nil || !valid {
+		return ctrl.Result{}, err
+	}
+	if valid, err = r.checkKeyPolicy(ctx, source, material.crt); err != nil || !valid {
+		return ctrl.Result{}, err
+	}
+
+	opts, err := parseRotationOptions(source)
+	if err != nil {
+		log.Error(err, "Source secret has invalid rotation annotations")
+		return ctrl.Result{}, nil
+	}
+
+	// Calculate kid and alg
+	incoming, err := newIncomingSlot(source, material, opts)
+	if err != nil {
+		log.Error(err, "Failed to derive kid and alg of the source certificate", "kidStrategy", opts.kidStrategy)
+		return ctrl.Result{}, nil
+	}
+	if !r.normalizeIncomingKey(ctx, source, &incoming, opts) {
+		return ctrl.Result{}, nil
+	}
+
+	if !targetExists && isPaused(source) {
+		// Target doesn't exist -> it is initialized once the source is resumed
+		log.Info("Rotation is paused, not creating target secret until the source is resumed")
+		r.Recorder.Eventf(source, nil, corev1.EventTypeNormal, "RotationPaused", "Rotate",
+			"Rotation is paused, target secret %s will be created once the source is resumed", target.Name)
+		return ctrl.Result{}, nil
+	}
+	return r.syncTarget(ctx, source, target, targetExists, incoming, opts, now)
+}
+
+// now returns the current time of the clock of the reconciler, or of the real clock if it has none.
+func (r *SecretReconciler) now() time.Time {
+	if r.Clock == nil {
+		return clock.RealClock{}.Now()
+	}
+	return r.Clock.Now()
+}
+
+// addFinalizer adds the finalizer to the source secret if it doesn't have it yet.
+func (r *SecretReconciler) addFinalizer(ctx context.Context, source *corev1.Secret) error {
+	if controllerutil.ContainsFinalizer(source, r.Finalizer) {
+		return nil
+	}
+	logf.FromContext(ctx).Info("Adding finalizer to source secret")
+	controllerutil.AddFinalizer(source, r.Finalizer)
+	return r.update(ctx, source)
+}
+
+// maintainTarget removes the revoked keys from the existing target and publishes its public JWK set. It runs on every
+// reconciliation before the source is validated, so that changes of the public JWK set config map are repaired even
+// if the source is invalid or paused.
+func (r *SecretReconciler) maintainTarget(
+	ctx context.Context,
+	source *corev1.Secret,
+	target *corev1.Secret,
+	now time.Time,
+) error {
+	if err := r.revokeKeys(ctx, source, target, now); err != nil {
+		return err
+	}
+	return r.publishPublicJWKS(ctx, source, target)
+}
+
+// getTarget gets the target secret named by the source. It returns false if the target doesn't exist yet or the
+// former source doesn't name one anymore, in which case the returned secret only holds its name and namespace.
+func (r *SecretReconciler) getTarget(ctx context.Context, source *corev1.Secret) (*corev1.Secret, bool, error) {
 	target := &corev1.Secret{}
 	targetNamespacedName := types.NamespacedName{
 		Namespace: source.Namespace,
 		Name:      source.Annotations["rotator.gw.ei.telekom.de/destination-secret-name"],
 	}
-	log = log.WithValues("target", targetNamespacedName)
-	err := r.Get(ctx, targetNamespacedName, target)
-	targetExists := true
-	if errors.IsNotFound(err) {
-		targetExists = false
+	var err error
+	if targetNamespacedName.Name != "" {
+		err = r.Get(ctx, targetNamespacedName, target)
+	}
+	if targetNamespacedName.Name == "" || errors.IsNotFound(err) {
+		target = &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      targetNamespacedName.Name,
+			Namespace: targetNamespacedName.Namespace,
+		}}
+		return target, false, nil
 	} else if err != nil {
-		log.Error(err, "Failed to get target secret")
+		logf.FromContext(ctx).Error(err, "Failed to get target secret", "target", targetNamespacedName)
+		return nil, false, err
+	}
+	return target, true, nil
+}
+
+// syncTarget creates the target secret or rotates its values, and publishes its public JWK set afterwards.
+func (r *SecretReconciler) syncTarget(
+	ctx context.Context,
+	source *corev1.Secret,
+	target *corev1.Secret,
+	targetExists bool,
+	incoming slot,
+	opts rotationOptions,
+	now time.Time,
+) (ctrl.Result, error) {
+	var result ctrl.Result
+	var err error
+	if !targetExists {
+		// Target doesn't exist -> initialize it
+		result, err = r.createTarget(ctx, source, target, incoming, opts, now)
+	} else {
+		// Target does exist -> rotate values
+		result, err = r.rotateTarget(ctx, source, target, incoming, opts, now)
+	}
+	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	if source.ObjectMeta.DeletionTimestamp.IsZero() && !controllerutil.ContainsFinalizer(source, r.Finalizer) {
-		// Source is not being deleted, add finalizer if not present
-		log.Info("Adding finalizer to source secret")
-		controllerutil.AddFinalizer(source, r.Finalizer)
-		if err = r.Update(ctx, source); err != nil {
-			return ctrl.Result{}, err
-		}
-	} else if !source.ObjectMeta.DeletionTimestamp.IsZero() {
-		return handleDeletion(ctx, r, source, target, targetExists)
+	// Publish the public JWK set of the target for resource servers
+	if err = r.publishPublicJWKS(ctx, source, target); err != nil {
+		return ctrl.Result{}, err
 	}
+	return result, nil
+}
 
-	// Calculate kid
-	kid := uuid.NewSHA1(uuid.Nil, source.Data["tls.crt"])
+// createTarget initializes the given target secret from the source secret and creates it in the cluster.
+func (r *SecretReconciler) createTarget(
+	ctx context.Context,
+	source *corev1.Secret,
+	target *corev1.Secret,
+	incoming slot,
+	opts rotationOptions,
+	now time.Time,
+) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
 
-	if !targetExists { //nolint:nestif // would be more complex if it was in separate method
-		// Target doesn't exist -> initialize it
-		target := initializeLocalTarget(source, kid)
+	*target = initializeLocalTarget(source, incoming, opts.retainedKeys)
+	recordSlotChanges(target, nil, now)
+	recordRotation(target, source, now)
+	recordKidStrategy(target, opts.kidStrategy)
+	if err := r.renderTargetOutputs(ctx, source, target); err != nil {
+		log.Error(err, "Failed to render the outputs of the target secret")
+		return ctrl.Result{}, err
+	}
 
-		if err = controllerutil.SetControllerReference(source, &target, r.Scheme); err != nil {
-			log.Error(err, "Failed to set controller reference")
-			return ctrl.Result{}, err
-		}
+	if err := controllerutil.SetControllerReference(source, target, r.Scheme); err != nil {
+		log.Error(err, "Failed to set controller reference")
+		return ctrl.Result{}, err
+	}
 
-		if err = r.Create(ctx, &target); err != nil {
-			log.Error(err, "Failed to create target secret")
-			return ctrl.Result{}, err
-		}
-		log.Info("Successfully created target secret")
-	} else {
-		// Target does exist -> rotate values
-		// Don't rotate if source is equal to next-tls
-		if string(source.Data["tls.crt"]) == string(target.Data["next-tls.crt"]) {
-			log.Info("Skipping update, source certificate is equal to certificate in target/next-tls.crt")
-			return ctrl.Result{}, nil
-		}
+	r.logSlotDiff(ctx, nil, target.Data)
+	if err := r.create(ctx, target); err != nil {
+		log.Error(err, "Failed to create target secret")
+		return ctrl.Result{}, err
+	}
+	log.Info("Successfully created target secret")
+	return ctrl.Result{}, nil
+}
 
-		log.Info("Updating target secret with rotated values")
-		updateLocalTargetData(target, source, kid)
+// rotateTarget rotates the values of the existing target secret and updates it in the cluster.
+// Revoked keys have already been removed from the target by revokeKeys and are never rotated into it.
+// Pending rollback requests are handled instead of rotating. The outputs derived from the slots, e.g. jwks.json,
+// are rendered again and the target is updated if they changed.
+// If the source or target is paused, rotations and rollbacks are held back.
+// Outside the rotation schedule of the source, changes of the source are staged until the next window starts.
+func (r *SecretReconciler) rotateTarget(
+	ctx context.Context,
+	source *corev1.Secret,
+	target *corev1.Secret,
+	incoming slot,
+	opts rotationOptions,
+	now time.Time,
+) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
 
-		if err = controllerutil.SetControllerReference(source, target, r.Scheme); err != nil {
-			log.Error(err, "Failed to set controller reference")
-			return ctrl.Result{}, err
-		}
+	original := maps.Clone(target.Data)
+	paused := isPaused(source) || isPaused(target)
+	if request, pending := pendingRollbackRequest(source, target); pending && !paused {
+		return r.rollbackTarget(ctx, source, target, request, now)
+	}
+
+	previous := maps.Clone(target.Data)
+	rotated, result := r.applySource(ctx, source, target, incoming, opts, paused, now)
+	result = earliestRequeue(result, r.checkActiveExpiry(ctx, source, target, opts.expiryWarning, now))
+	// The outputs are rendered on every reconciliation, so that outputs missing or changed in the target are repaired
+	if err := r.renderTargetOutputs(ctx, source, target); err != nil {
+		log.Error(err, "Failed to render the outputs of the target secret")
+		return ctrl.Result{}, err
+	}
+	if !rotated && maps.EqualFunc(original, target.Data, bytes.Equal) {
+		return result, nil
+	}
 
-		// Update the target secret
-		if err = r.Update(ctx, target); err != nil {
-			log.Error(err, "Failed to update target secret")
+	// Rotations that leave the slots unchanged, e.g. a forced rotation without anything to promote, are not recorded
+	changed := rotated && slotsChanged(previous, target.Data)
+	if changed {
+		if err := r.recordHistory(ctx, source, target, previous, opts); err != nil {
 			return ctrl.Result{}, err
 		}
-		log.Info("Successfully updated target secret with rotated values")
 	}
-	return ctrl.Result{}, nil
+
+	if err := controllerutil.SetControllerReference(source, target, r.Scheme); err != nil {
+		log.Error(err, "Failed to set controller reference")
+		return ctrl.Result{}, err
+	}
+
+	recordSlotChanges(target, original, now)
+	if changed {
+		recordRotation(target, source, now)
+		recordKidStrategy(target, opts.kidStrategy)
+	}
+
+	// Update the target secret
+	r.logSlotDiff(ctx, original, target.Data)
+	if err := r.update(ctx, target); err != nil {
+		log.Error(err, "Failed to update target secret")
+		return ctrl.Result{}, err
+	}
+	log.Info("Successfully updated target secret with rotated values")
+	return result, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
-// It filters the events to only those secrets with the source annotation.
+// It filters the events to only those secrets with the source annotation or the finalizer. Changes to owned target
+// secrets, e.g. revoked kids, and owned public JWK set config maps trigger a reconciliation of their source. Changes
+// to a key policy config map trigger a reconciliation of all sources in its namespace, changes to a password secret
+// of all sources reading their keystore password from it, looked up with an index of the sources by their password
+// secrets.
+// In dry-run mode, events are logged instead of being recorded.
 func (r *SecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.DryRun {
+		r.Recorder = dryRunRecorder{log: mgr.GetLogger().WithName("events")}
+	} else if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorder(eventRecorderName)
+	}
+	if err := r.registerKeyPolicyViolationsMetric(); err != nil {
+		return err
+	}
+
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Secret{}, passwordSecretField,
+		r.passwordSecretNames)
+	if err != nil {
+		return fmt.Errorf("indexing the password secrets of the sources: %w", err)
+	}
+
+	// Former sources still carrying the finalizer are reconciled once more to release their target
 	secretPredicate := predicate.NewPredicateFuncs(func(obj client.Object) bool {
 		secret, ok := obj.(*corev1.Secret)
-		if !ok {
-			return false
-		}
-
-		sourceVal, sourceExists := secret.Annotations[r.SourceAnnotation]
-		targetNameVal, targetNameExists := secret.Annotations[r.TargetNameAnnotation]
-		return sourceExists && sourceVal == "true" && targetNameExists && len(targetNameVal) > 0
+		return ok && (r.isSource(secret) || controllerutil.ContainsFinalizer(secret, r.Finalizer))
+	})
+	keyPolicyPredicate := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetName() == keyPolicyConfigMapName
 	})
 
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Secret{}).
+		For(&corev1.Secret{}, builder.WithPredicates(secretPredicate)).
 		Named("key-secret").
-		WithEventFilter(secretPredicate).
 		Owns(&corev1.Secret{}).
+		Owns(&corev1.ConfigMap{}).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.sourcesInNamespace),
+			builder.WithPredicates(keyPolicyPredicate)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.sourcesWithPasswordSecret)).
 		Complete(r)
 }
 
-// initializeLocalTarget initializes a target secret with the given source secret and kid in the next-tls.* fields.
+// isSource returns whether the secret has the source annotation and names a target.
+func (r *SecretReconciler) isSource(secret *corev1.Secret) bool {
+	sourceVal, sourceExists := secret.Annotations[r.SourceAnnotation]
+	targetNameVal, targetNameExists := secret.Annotations[r.TargetNameAnnotation]
+	return sourceExists && sourceVal == "true" && targetNameExists && len(targetNameVal) > 0
+}
+
+// sourcesInNamespace returns a reconcile request for every source in the namespace of the given object.
+func (r *SecretReconciler) sourcesInNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	secrets := &corev1.SecretList{}
+	if err := r.List(ctx, secrets, client.InNamespace(obj.GetNamespace())); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list the source secrets", "namespace", obj.GetNamespace())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if r.isSource(secret) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(secret)})
+		}
+	}
+	return requests
+}
+
+// initializeLocalTarget initializes a target secret with the given incoming slot of the source secret in the
+// next-tls.* fields. All other slots for the given number of retained keys are left empty.
 // It does not create the secret in the cluster.
-func initializeLocalTarget(source *corev1.Secret, kid uuid.UUID) corev1.Secret {
+func initializeLocalTarget(source *corev1.Secret, incoming slot, retainedKeys int) corev1.Secret {
+	data := map[string][]byte{}
+	for _, name := range slotNames(retainedKeys) {
+		writeSlot(data, name, slot{})
+	}
+	writeSlot(data, nextSlot, incoming)
+
 	return corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      source.Annotations["rotator.gw.ei.telekom.de/destination-secret-name"],
 			Namespace: source.Namespace,
 		},
 		Type: corev1.SecretTypeTLS,
-		Data: map[string][]byte{
-			"next-tls.crt": source.Data["tls.crt"],
-			"next-tls.key": source.Data["tls.key"],
-			"next-tls.kid": []byte(kid.String()),
-			"tls.crt":      {},
-			"tls.key":      {},
-			"tls.kid":      {},
-			"prev-tls.crt": {},
-			"prev-tls.key": {},
-			"prev-tls.kid": {},
-		},
+		Data: data,
 	}
 }
 
-// updateLocalTargetData updates the target secret with the given source secret and kid by moving the
-// - values from the tls.* fields to the prev-tls.* fields
+// updateLocalTargetData updates the target secret with the given incoming slot of the source by shifting every slot
+// by one position:
+// - the values from the previous slots move to the next older previous slot, dropping the oldest one
+// - the values from the tls.* fields to the (newest) previous slot
 // - the values from the next-tls.* fields to the tls.*. fields
-// - the values from the source secret to the next-tls.* fields (and generating a new kid)
+// - the values of the incoming slot to the next-tls.* fields
+// If the next-tls.* fields are empty, e.g. after a forced promotion, the slots are not shifted and only the
+// next-tls.* fields are filled.
+// The number of previous slots is given by retainedKeys. If it changed since the last rotation, the existing
+// previous slots are carried over into the new layout as far as they fit.
 // It does not update the secret in the cluster.
-func updateLocalTargetData(target *corev1.Secret, source *corev1.Secret, kid uuid.UUID) {
+func updateLocalTargetData(target *corev1.Secret, incoming slot, retainedKeys int) {
 	// Create updated data map
-	updatedData := map[string][]byte{
-		// Move tls to prev-tls
-		"prev-tls.crt": target.Data["tls.crt"],
-		"prev-tls.key": target.Data["tls.key"],
-		"prev-tls.kid": target.Data["tls.kid"],
-
-		// Move new-tls to tls
-		"tls.crt": target.Data["next-tls.crt"],
-		"tls.key": target.Data["next-tls.key"],
-		"tls.kid": target.Data["next-tls.kid"],
-
-		// Copy source secret data to next-tls
-		"next-tls.crt": source.Data["tls.crt"],
-		"next-tls.key": source.Data["tls.key"],
-		"next-tls.kid": []byte(kid.String()),
+	var updatedData map[string][]byte
+	if len(target.Data["next-tls.kid"]) == 0 {
+		updatedData = shiftedSlots(target.Data, retainedKeys, 0)
+	} else {
+		updatedData = shiftedSlots(target.Data, retainedKeys, 1)
 	}
 
+	// Copy source secret data to next-tls
+	writeSlot(updatedData, nextSlot, incoming)
+
 	// Update the target secret
 	target.Data = updatedData
 }
 
-// handleDeletion prevents garbage collection of target secret if the source secret is being deleted.
+// promoteLocalTargetData shifts every slot of the target secret by one position like updateLocalTargetData,
+// but leaves the next-tls.* fields empty instead of filling them from the source.
+// It does not update the secret in the cluster.
+func promoteLocalTargetData(target *corev1.Secret, retainedKeys int) {
+	target.Data = shiftedSlots(target.Data, retainedKeys, 1)
+}
+
+// rotateLocalTarget decides whether the target has to be rotated and, if so, rotates its values.
+// A renewed certificate whose key is already in the target replaces the certificate of that slot instead, and so
+// does a certificate already in the target with another chain.
+// The certificate in next-tls.* is only promoted once it is valid and never after it has expired.
+// It returns whether the target was changed and the result to return from the reconciliation.
+// It does not update the secret in the cluster.
+func (r *SecretReconciler) rotateLocalTarget(
+	ctx context.Context,
+	source *corev1.Secret,
+	target *corev1.Secret,
+	incoming slot,
+	retained int,
+	now time.Time,
+) (bool, ctrl.Result) {
+	log := logf.FromContext(ctx)
+
+	rotateRequest, forced := pendingRotateRequest(source, target)
+	// The source is compared against all slots, so that a key is never placed in two slots
+	sourceSlot, sourceInTarget := slotOfCert(target.Data, incoming.crt)
+	// With kids derived from the key, a certificate renewed with the same key keeps its kid and slot
+	kidSlot, kidInTarget := slotOfKid(target.Data, string(incoming.kid))
+	renewed := kidInTarget && !sourceInTarget &&
+		samePublicKey(target.Data[kidSlot+".crt"], incoming.crt)
+	// A certificate whose chain changed keeps its kid and slot as well, only its chain is updated
+	if leafSlot, ok := slotOfLeaf(target.Data, incoming.crt); ok && !sourceInTarget && !renewed {
+		kidSlot, renewed = leafSlot, true
+		incoming.kid = target.Data[leafSlot+".kid"]
+	}
+
+	promotes := !renewed && len(target.Data["next-tls.kid"]) > 0 &&
+		(!sourceInTarget || (forced && sourceSlot == nextSlot))
+	if promotes {
+		if wait := r.holdBackPromotion(ctx, source, target, now); wait > 0 {
+			return false, ctrl.Result{RequeueAfter: wait}
+		}
+		sourceSlot, sourceInTarget = slotOfCert(target.Data, incoming.crt)
+	}
+
+	switch {
+	case renewed:
+		log.Info("Renewing certificate in target, its key and kid are unchanged", "slot", kidSlot)
+		writeSlot(target.Data, kidSlot, incoming)
+	case forced && sourceSlot == nextSlot:
+		// Forced rotation without a new source -> promote next-tls and leave it empty until the source changes
+		log.Info("Promoting target secret values as requested", "rotateRequest", rotateRequest)
+		promoteLocalTargetData(target, retained)
+	case forced && sourceInTarget:
+		log.Info("Nothing to promote, source certificate is already in target",
+			"slot", sourceSlot, "rotateRequest", rotateRequest)
+	case forced:
+		log.Info("Updating target secret with rotated values as requested", "rotateRequest", rotateRequest)
+		updateLocalTargetData(target, incoming, retained)
+	case sourceInTarget:
+		// Don't rotate if the source is already in one of the slots, e.g. equal to next-tls
+		log.Info("Skipping update, source certificate is already in target", "slot", sourceSlot)
+		return false, ctrl.Result{}
+	default:
+		// Don't rotate if next-tls hasn't been published for long enough
+		remaining, err := remainingNextAge(source, target, now)
+		if err != nil {
+			log.Error(err, "Failed to determine whether next-tls has been published long enough")
+			return false, ctrl.Result{}
+		}
+		if remaining > 0 {
+			log.Info("Holding back rotation, next-tls has not been published for min-next-age yet",
+				"requeueAfter", remaining)
+			return false, ctrl.Result{RequeueAfter: remaining}
+		}
+
+		log.Info("Updating target secret with rotated values")
+		updateLocalTargetData(target, incoming, retained)
+	}
+
+	if forced {
+		metav1.SetMetaDataAnnotation(&target.ObjectMeta, lastRotateRequestAnnotation, rotateRequest)
+	}
+	return true, ctrl.Result{}
+}
+
+// applySource rotates the source into the target, unless rotation is paused, the kid of the source has been revoked
+// or blocked, or the source changed outside the rotation schedule.
+// It returns whether the target was changed and the result to return from the reconciliation.
+// It does not update the secret in the cluster.
+func (r *SecretReconciler) applySource(
+	ctx context.Context,
+	source *corev1.Secret,
+	target *corev1.Secret,
+	incoming slot,
+	opts rotationOptions,
+	paused bool,
+	now time.Time,
+) (bool, ctrl.Result) {
+	log := logf.FromContext(ctx)
+
+	kid := string(incoming.kid)
+	previous := maps.Clone(target.Data)
+	var result ctrl.Result
+	rotated := false
+	switch {
+	case paused:
+		log.Info("Rotation is paused, holding back changes of the source",
+			"pendingChange", hasPendingChange(source, target, incoming.crt))
+		if hasPendingChange(source, target, incoming.crt) {
+			r.Recorder.Eventf(target, source, corev1.EventTypeNormal, "RotationPaused", "Rotate",
+				"Rotation is paused, the pending change of source %s is applied once rotation is resumed", source.Name)
+		}
+	case isRevoked(target, kid):
+		log.Info("Refusing to rotate source into target secret, its kid has been revoked", "kid", kid)
+		r.Recorder.Eventf(source, target, corev1.EventTypeWarning, "RevokedKeyRejected", "Rotate",
+			"Refusing to rotate revoked kid %s into target secret %s", kid, target.Name)
+	case isBlocked(target, kid):
+		log.Info("Refusing to rotate source into target secret, its rotation has been rolled back", "kid", kid)
+		r.Recorder.Eventf(source, target, corev1.EventTypeWarning, "BlockedKeyRejected", "Rotate",
+			"Refusing to rotate kid %s into target secret %s again after a rollback", kid, target.Name)
+	case !inSchedule(opts.schedule, now) && hasPendingChange(source, target, incoming.crt):
+		result = r.stageRotation(ctx, source, target, opts.schedule, now)
+	default:
+		rotated, result = r.rotateLocalTarget(ctx, source, target, incoming, opts.retainedKeys, now)
+		if rotated && r.rejectDuplicateKids(ctx, source, target) {
+			target.Data = previous
+			return false, ctrl.Result{}
+		}
+	}
+	return rotated, result
+}
+
+// stageRotation holds back a pending change of the source outside the rotation schedule and returns a result that
+// requeues the reconciliation at the start of the next window.
+func (r *SecretReconciler) stageRotation(
+	ctx context.Context,
+	source *corev1.Secret,
+	target *corev1.Secret,
+	schedule rotationSchedule,
+	now time.Time,
+) ctrl.Result {
+	log := logf.FromContext(ctx)
+
+	start, ok := schedule.nextStart(now)
+	if !ok {
+		log.Error(nil, "Rotation schedule of the source secret doesn't allow any rotations anymore")
+		return ctrl.Result{}
+	}
+	log.Info("Staging change of the source until the next rotation window", "windowStart", start)
+	r.Recorder.Eventf(target, source, corev1.EventTypeNormal, "RotationStaged", "Rotate",
+		"Change of source %s is staged until the next rotation window starts at %s",
+		source.Name, start.Format(time.RFC3339))
+	return ctrl.Result{RequeueAfter: start.Sub(now)}
+}
+
+// rejectDuplicateKids returns whether the rotated target holds the same kid in more than one slot, which would
+// produce a JWK set with duplicate kids. In that case, it logs the duplicates and records a warning event.
+func (r *SecretReconciler) rejectDuplicateKids(ctx context.Context, source *corev1.Secret, target *corev1.Secret) bool {
+	duplicates := duplicateKids(target.Data)
+	if len(duplicates) == 0 {
+		return false
+	}
+	logf.FromContext(ctx).Error(nil, "Refusing to rotate, target secret would hold duplicate kids", "kids", duplicates)
+	r.Recorder.Eventf(source, target, corev1.EventTypeWarning, "DuplicateKidRejected", "Rotate",
+		"Refusing to rotate, target secret %s would hold duplicate kids %s",
+		target.Name, strings.Join(duplicates, ", "))
+	return true
+}
+
+// pendingRotateRequest returns the rotate request of the source and whether it has not been handled yet.
+func pendingRotateRequest(source *corev1.Secret, target *corev1.Secret) (string, bool) {
+	request, ok := source.Annotations[rotateRequestAnnotation]
+	if !ok || request == "" {
+		return "", false
+	}
+	return request, request != target.Annotations[lastRotateRequestAnnotation]
+}
+
+// remainingNextAge returns how long a rotation has to be held back so that the current next-tls.* values are
+// published for at least the duration configured in the min-next-age annotation of the source.
+// Targets without a recorded publish timestamp or with empty next-tls.* fields are never held back.
+func remainingNextAge(source *corev1.Secret, target *corev1.Secret, now time.Time) (time.Duration, error) {
+	minAgeVal, ok := source.Annotations[minNextAgeAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	minAge, err := time.ParseDuration(minAgeVal)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", minNextAgeAnnotation, err)
+	}
+
+	publishedAtVal, ok := target.Annotations[publishedAtAnnotation(nextSlot)]
+	if !ok || len(target.Data["next-tls.kid"]) == 0 {
+		return 0, nil
+	}
+	publishedAt, err := time.Parse(time.RFC3339, publishedAtVal)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", publishedAtAnnotation(nextSlot), err)
+	}
+
+	return max(publishedAt.Add(minAge).Sub(now), 0), nil
+}
+
+// handleDeletion prevents garbage collection of target secret if the source secret is being deleted, or releases
+// the target if the secret is no longer a source, so that later changes of the target don't enroll it again.
 func handleDeletion(
 	ctx context.Context,
 	r *SecretReconciler,
@@ -208,8 +675,9 @@ func handleDeletion(
 		return ctrl.Result{}, nil
 	}
 
-	log.Info("Source secret is under deletion. Keeping target and removing owner reference")
-	if targetExists {
+	log.Info("Source secret is under deletion or no longer a source. Keeping target and removing owner reference")
+	// The owner reference may already be gone if removing the finalizer failed before
+	if targetExists && metav1.IsControlledBy(target, source) {
 		// Remove the owner reference so the target continues to exist without the source
 		err := controllerutil.RemoveOwnerReference(source, target, r.Scheme)
 		if err != nil {
@@ -218,15 +686,16 @@ func handleDeletion(
 		}
 		// Remove deletion timestamp to prevent deletion
 		target.SetDeletionTimestamp(nil)
-		if err = r.Update(ctx, target); err != nil {
+		if err = r.update(ctx, target); err != nil {
 			log.Error(err, "Failed to remove the deletion timestamp")
 			return ctrl.Result{}, err
 		}
 	}
 	// Remove the finalizer
 	controllerutil.RemoveFinalizer(source, r.Finalizer)
-	if err := r.Update(ctx, source); err != nil {
+	if err := r.update(ctx, source); err != nil {
 		return ctrl.Result{}, err
 	}
+	r.countedViolations.Delete(client.ObjectKeyFromObject(source))
 	return ctrl.Result{}, nil
 }
@@ -6,23 +6,211 @@ package controller_test
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"time"
 
+	"github.com/go-logr/logr/funcr"
 	"github.com/google/uuid"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"gw.ei.telekom.de/rotator/internal/controller"
+	"gw.ei.telekom.de/rotator/internal/keystore"
 	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
-func generateUuid(input string) []byte {
-	return []byte(uuid.NewSHA1(uuid.Nil, []byte(input)).String())
+// testKeyPair holds a generated PEM encoded certificate and its private key.
+type testKeyPair struct {
+	crt []byte
+	key []byte
+}
+
+// testKeyPairs caches the generated key pairs by name, so that a name always refers to the same certificate.
+var testKeyPairs = map[string]testKeyPair{}
+
+// testKeyPairFor returns the key pair generated for the given name.
+func testKeyPairFor(name string) testKeyPair {
+	if pair, ok := testKeyPairs[name]; ok {
+		return pair
+	}
+	crt, key := generateCert(name)
+	testKeyPairs[name] = testKeyPair{crt: crt, key: key}
+	return testKeyPairs[name]
+}
+
+// testCert returns the PEM encoded certificate generated for the given name.
+func testCert(name string) []byte {
+	return testKeyPairFor(name).crt
+}
+
+// testKey returns the PEM encoded private key of the certificate generated for the given name.
+func testKey(name string) []byte {
+	return testKeyPairFor(name).key
+}
+
+// generateUuid returns the kid of the certificate generated for the given name.
+func generateUuid(name string) []byte {
+	return []byte(uuid.NewSHA1(uuid.Nil, testCert(name)).String())
+}
+
+// jwkThumbprint returns the RFC 7638 thumbprint of the EC key generated for the given name.
+func jwkThumbprint(name string) string {
+	block, _ := pem.Decode(testKey(name))
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	Expect(err).NotTo(HaveOccurred())
+	point, err := key.(*ecdsa.PrivateKey).PublicKey.Bytes()
+	Expect(err).NotTo(HaveOccurred())
+	members := fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`,
+		base64.RawURLEncoding.EncodeToString(point[1:33]), base64.RawURLEncoding.EncodeToString(point[33:]))
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// jwkCoordinate returns the base64url encoded x (0) or y (1) coordinate of the EC key generated for the given name.
+func jwkCoordinate(name string, i int) string {
+	block, _ := pem.Decode(testKey(name))
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	Expect(err).NotTo(HaveOccurred())
+	point, err := key.(*ecdsa.PrivateKey).PublicKey.Bytes()
+	Expect(err).NotTo(HaveOccurred())
+	return base64.RawURLEncoding.EncodeToString(point[1+32*i : 33+32*i])
+}
+
+// generateCert returns a PEM encoded self-signed certificate and its private key with the given common name.
+func generateCert(commonName string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	return generateCertForKey(commonName, key)
+}
+
+// generateCertForKey returns a PEM encoded self-signed certificate for the given key and the PEM encoded key.
+func generateCertForKey(commonName string, key crypto.Signer) ([]byte, []byte) {
+	return generateCertValidBetween(commonName, key, time.Now().Add(-time.Hour), time.Now().AddDate(1, 0, 0))
+}
+
+// generateCertValidBetween returns a PEM encoded self-signed certificate for the given key, which is valid between
+// notBefore and notAfter, and the PEM encoded key.
+func generateCertValidBetween(
+	commonName string,
+	key crypto.Signer,
+	notBefore time.Time,
+	notAfter time.Time,
+) ([]byte, []byte) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	Expect(err).NotTo(HaveOccurred())
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})
+}
+
+// generateChain returns a PEM encoded leaf certificate followed by the intermediate CA certificate that issued it,
+// the PEM encoded key of the leaf and the DER encoded certificates of the leaf and the intermediate.
+func generateChain(commonName string) ([]byte, []byte, [][]byte) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName + "-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	Expect(err).NotTo(HaveOccurred())
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caTemplate, &key.PublicKey, caKey)
+	Expect(err).NotTo(HaveOccurred())
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	crt := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDer})...)
+	return crt, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}), [][]byte{der, caDer}
+}
+
+// policyViolationCount returns the number of counted violations of the given rule by the given source in the default
+// namespace.
+func policyViolationCount(g Gomega, source string, rule string) float64 {
+	families, err := metrics.Registry.Gather()
+	g.Expect(err).NotTo(HaveOccurred())
+	for _, family := range families {
+		if family.GetName() != "rotator_key_policy_violations_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["namespace"] == "default" && labels["source"] == source && labels["rule"] == rule {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+// plannedSlotChanges reconciles the given source with the dry-run reconciler and returns the slot changes it logged.
+func plannedSlotChanges(
+	ctx context.Context,
+	reconciler *controller.SecretReconciler,
+	name types.NamespacedName,
+) []map[string]any {
+	var changes []map[string]any
+	logger := funcr.NewJSON(func(obj string) {
+		var entry struct {
+			Msg  string           `json:"msg"`
+			Diff []map[string]any `json:"diff"`
+		}
+		Expect(json.Unmarshal([]byte(obj), &entry)).To(Succeed())
+		if entry.Msg == "Dry run, planned changes of the target secret" {
+			changes = append(changes, entry.Diff...)
+		}
+	}, funcr.Options{})
+	_, err := reconciler.Reconcile(logf.IntoContext(ctx, logger), ctrl.Request{NamespacedName: name})
+	Expect(err).NotTo(HaveOccurred())
+	return changes
 }
 
 var _ = Describe("Secret Controller", Serial, func() {
@@ -61,8 +249,8 @@ var _ = Describe("Secret Controller", Serial, func() {
 				},
 				Type: corev1.SecretTypeTLS,
 				Data: map[string][]byte{
-					"tls.crt": []byte("cert"),
-					"tls.key": []byte("key"),
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
 				},
 			}
 			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
@@ -80,8 +268,8 @@ var _ = Describe("Secret Controller", Serial, func() {
 
 		It("creates a target secret with the correct name and namespace", func() {
 			By("setting the key and crt from the source in next-tls.*", func() {
-				Expect(target.Data["next-tls.crt"]).To(Equal([]byte("cert")))
-				Expect(target.Data["next-tls.key"]).To(Equal([]byte("key")))
+				Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert")))
+				Expect(target.Data["next-tls.key"]).To(Equal(testKey("cert")))
 			})
 
 			By("leaving tls.* and prev-tls.* empty", func() {
@@ -96,7 +284,32 @@ var _ = Describe("Secret Controller", Serial, func() {
 			By("generating a UUID based on the cert and setting it as a kid", func() {
 				Expect(
 					target.Data["next-tls.kid"],
-				).To(Equal(generateUuid(string(source.Data["tls.crt"]))))
+				).To(Equal(generateUuid("cert")))
+			})
+
+			By("detecting the alg of the EC P-256 key", func() {
+				Expect(target.Data["next-tls.alg"]).To(Equal([]byte("ES256")))
+			})
+
+			By("publishing the public key of the certificate", func() {
+				block, _ := pem.Decode(target.Data["next-tls.pub"])
+				Expect(block).NotTo(BeNil())
+				Expect(block.Type).To(Equal("PUBLIC KEY"))
+				pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+				Expect(err).NotTo(HaveOccurred())
+
+				crtBlock, _ := pem.Decode(testCert("cert"))
+				cert, err := x509.ParseCertificate(crtBlock.Bytes)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cert.PublicKey.(*ecdsa.PublicKey).Equal(pub)).To(BeTrue())
+			})
+
+			By("publishing the self-signed certificate as leaf and x5c without a chain", func() {
+				block, _ := pem.Decode(testCert("cert"))
+				Expect(target.Data["next-tls.leaf"]).To(Equal(testCert("cert")))
+				Expect(target.Data["next-tls.chain"]).To(BeEmpty())
+				Expect(target.Data["next-tls.x5c"]).
+					To(MatchJSON(fmt.Sprintf("[%q]", base64.StdEncoding.EncodeToString(block.Bytes))))
 			})
 
 			By("setting secret type tls", func() {
@@ -110,6 +323,151 @@ var _ = Describe("Secret Controller", Serial, func() {
 			})
 		})
 
+		It("renders the public JWK set of the slots into jwks.json", func() {
+			crtBlock, _ := pem.Decode(testCert("cert"))
+			thumbprint := sha256.Sum256(crtBlock.Bytes)
+			jwk := fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":%q,"y":%q,"kid":%q,"alg":"ES256","use":"sig",`+
+				`"x5c":[%q],"x5t#S256":%q}`, jwkCoordinate("cert", 0), jwkCoordinate("cert", 1), generateUuid("cert"),
+				base64.StdEncoding.EncodeToString(crtBlock.Bytes), base64.RawURLEncoding.EncodeToString(thumbprint[:]))
+
+			By("publishing only the key in next-tls.*, omitting the empty slots", func() {
+				Expect(target.Data["jwks.json"]).To(MatchJSON(`{"keys":[` + jwk + `]}`))
+			})
+
+			By("changing the source", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Data["tls.crt"] = testCert("cert-rotation-1")
+				source.Data["tls.key"] = testKey("cert-rotation-1")
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("listing the active key first, followed by the key in next-tls.*", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					var jwks struct {
+						Keys []struct {
+							Kid string `json:"kid"`
+						} `json:"keys"`
+					}
+					g.Expect(json.Unmarshal(target.Data["jwks.json"], &jwks)).To(Succeed())
+					g.Expect(jwks.Keys).To(HaveLen(2))
+					g.Expect(jwks.Keys[0].Kid).To(Equal(string(generateUuid("cert"))))
+					g.Expect(jwks.Keys[1].Kid).To(Equal(string(generateUuid("cert-rotation-1"))))
+				}, timeout, interval).Should(Succeed(), "controller did not rotate the target secret within timeout")
+			})
+
+			By("repairing a modified jwks.json", func() {
+				rendered := target.Data["jwks.json"]
+				target.Data["jwks.json"] = []byte(`{"keys":[]}`)
+				Expect(k8sClient.Update(ctx, target)).To(Succeed(), "update of target secret by test runner failed")
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["jwks.json"]).To(Equal(rendered))
+				}, timeout, interval).Should(Succeed(), "controller did not repair jwks.json within timeout")
+			})
+		})
+
+		It("renders the private JWK set and the active kid into the target", func() {
+			type privateJWKS struct {
+				Keys []struct {
+					Kid string `json:"kid"`
+					D   string `json:"d"`
+				} `json:"keys"`
+			}
+			privateKey := func(name string) string {
+				block, _ := pem.Decode(testKey(name))
+				key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+				Expect(err).NotTo(HaveOccurred())
+				d, err := key.(*ecdsa.PrivateKey).Bytes()
+				Expect(err).NotTo(HaveOccurred())
+				return base64.RawURLEncoding.EncodeToString(d)
+			}
+
+			By("publishing the private key in next-tls.* without an active kid", func() {
+				var jwks privateJWKS
+				Expect(json.Unmarshal(target.Data["jwks-private.json"], &jwks)).To(Succeed())
+				Expect(jwks.Keys).To(HaveLen(1))
+				Expect(jwks.Keys[0].Kid).To(Equal(string(generateUuid("cert"))))
+				Expect(jwks.Keys[0].D).To(Equal(privateKey("cert")))
+				Expect(target.Data).To(HaveKeyWithValue("active.kid", BeEmpty()))
+			})
+
+			By("changing the source", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Data["tls.crt"] = testCert("cert-rotation-1")
+				source.Data["tls.key"] = testKey("cert-rotation-1")
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("pointing the active kid at the promoted key, listed first", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["active.kid"]).To(Equal(generateUuid("cert")))
+					var jwks privateJWKS
+					g.Expect(json.Unmarshal(target.Data["jwks-private.json"], &jwks)).To(Succeed())
+					g.Expect(jwks.Keys).To(HaveLen(2))
+					g.Expect(jwks.Keys[0].Kid).To(Equal(string(target.Data["active.kid"])))
+					g.Expect(jwks.Keys[0].D).To(Equal(privateKey("cert")))
+					g.Expect(jwks.Keys[1].D).To(Equal(privateKey("cert-rotation-1")))
+				}, timeout, interval).Should(Succeed(), "controller did not rotate the target secret within timeout")
+			})
+		})
+
+		It("records the rotation bookkeeping in the annotations of the target secret", func() {
+			By("recording the creation as the first generation", func() {
+				Expect(target.Annotations).To(HaveKeyWithValue("rotator.gw.ei.telekom.de/rotation-generation", "1"))
+				Expect(target.Annotations).To(HaveKey("rotator.gw.ei.telekom.de/last-rotation-time"))
+				Expect(target.Annotations).To(HaveKey("rotator.gw.ei.telekom.de/next-tls-published-at"))
+				Expect(target.Annotations).NotTo(HaveKey("rotator.gw.ei.telekom.de/tls-published-at"))
+				Expect(target.Annotations).
+					To(HaveKeyWithValue("rotator.gw.ei.telekom.de/source-uid", string(source.UID)))
+			})
+
+			By("changing the source", func() {
+				err := k8sClient.Get(
+					ctx,
+					types.NamespacedName{Name: "source", Namespace: namespace},
+					source,
+				)
+				Expect(err).ToNot(HaveOccurred())
+				source.Data["tls.crt"] = testCert("cert-rotation-1")
+				source.Data["tls.key"] = testKey("cert-rotation-1")
+				Expect(
+					k8sClient.Update(ctx, source),
+				).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("recording the rotation", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Annotations).
+						To(HaveKeyWithValue("rotator.gw.ei.telekom.de/rotation-generation", "2"))
+					g.Expect(target.Annotations).
+						To(HaveKeyWithValue("rotator.gw.ei.telekom.de/source-resource-version", source.ResourceVersion))
+					g.Expect(target.Annotations).
+						To(HaveKeyWithValue("rotator.gw.ei.telekom.de/source-uid", string(source.UID)))
+				}, timeout, interval).Should(Succeed(), "controller did not record the rotation within timeout")
+			})
+
+			By("recording when each slot was filled", func() {
+				activeAt, err := time.Parse(time.RFC3339, target.Annotations["rotator.gw.ei.telekom.de/tls-published-at"])
+				Expect(err).NotTo(HaveOccurred())
+				nextAt, err := time.Parse(time.RFC3339, target.Annotations["rotator.gw.ei.telekom.de/next-tls-published-at"])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(activeAt).To(Equal(nextAt))
+				rotatedAt, err := time.Parse(time.RFC3339, target.Annotations["rotator.gw.ei.telekom.de/last-rotation-time"])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rotatedAt).To(Equal(activeAt))
+				Expect(target.Annotations).NotTo(HaveKey("rotator.gw.ei.telekom.de/prev-tls-published-at"))
+			})
+		})
+
 		Context("and the source secret changes", func() {
 			Context("and the cert in source and next-tls.crt are equal", func() {
 				BeforeEach(func() {
@@ -145,8 +503,8 @@ var _ = Describe("Secret Controller", Serial, func() {
 							source,
 						)
 						Expect(err).ToNot(HaveOccurred())
-						source.Data["tls.crt"] = []byte("cert-rotation-1")
-						source.Data["tls.key"] = []byte("key-rotation-1")
+						source.Data["tls.crt"] = testCert("cert-rotation-1")
+						source.Data["tls.key"] = testKey("cert-rotation-1")
 						Expect(
 							k8sClient.Update(ctx, source),
 						).To(Succeed(), "update of source secret by test runner failed")
@@ -157,13 +515,13 @@ var _ = Describe("Secret Controller", Serial, func() {
 							g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
 								To(Succeed())
 							g.Expect(target.Data["next-tls.crt"]).
-								To(Equal([]byte("cert-rotation-1")))
+								To(Equal(testCert("cert-rotation-1")))
 							g.Expect(target.Data["next-tls.key"]).
-								To(Equal([]byte("key-rotation-1")))
+								To(Equal(testKey("cert-rotation-1")))
 							g.Expect(target.Data["next-tls.kid"]).
 								To(Equal(generateUuid("cert-rotation-1")))
-							g.Expect(target.Data["tls.crt"]).To(Equal([]byte("cert")))
-							g.Expect(target.Data["tls.key"]).To(Equal([]byte("key")))
+							g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+							g.Expect(target.Data["tls.key"]).To(Equal(testKey("cert")))
 							g.Expect(target.Data["tls.kid"]).To(Equal(generateUuid("cert")))
 							g.Expect(target.Data["prev-tls.crt"]).To(BeEmpty())
 							g.Expect(target.Data["prev-tls.key"]).To(BeEmpty())
@@ -178,8 +536,8 @@ var _ = Describe("Secret Controller", Serial, func() {
 							source,
 						)
 						Expect(err).ToNot(HaveOccurred())
-						source.Data["tls.crt"] = []byte("cert-rotation-2")
-						source.Data["tls.key"] = []byte("key-rotation-2")
+						source.Data["tls.crt"] = testCert("cert-rotation-2")
+						source.Data["tls.key"] = testKey("cert-rotation-2")
 						Expect(
 							k8sClient.Update(ctx, source),
 						).To(Succeed(), "update of source secret by test runner failed")
@@ -190,17 +548,17 @@ var _ = Describe("Secret Controller", Serial, func() {
 							g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
 								To(Succeed())
 							g.Expect(target.Data["next-tls.crt"]).
-								To(Equal([]byte("cert-rotation-2")))
+								To(Equal(testCert("cert-rotation-2")))
 							g.Expect(target.Data["next-tls.key"]).
-								To(Equal([]byte("key-rotation-2")))
+								To(Equal(testKey("cert-rotation-2")))
 							g.Expect(target.Data["next-tls.kid"]).
 								To(Equal(generateUuid("cert-rotation-2")))
-							g.Expect(target.Data["tls.crt"]).To(Equal([]byte("cert-rotation-1")))
-							g.Expect(target.Data["tls.key"]).To(Equal([]byte("key-rotation-1")))
+							g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+							g.Expect(target.Data["tls.key"]).To(Equal(testKey("cert-rotation-1")))
 							g.Expect(target.Data["tls.kid"]).
 								To(Equal(generateUuid("cert-rotation-1")))
-							g.Expect(target.Data["prev-tls.crt"]).To(Equal([]byte("cert")))
-							g.Expect(target.Data["prev-tls.key"]).To(Equal([]byte("key")))
+							g.Expect(target.Data["prev-tls.crt"]).To(Equal(testCert("cert")))
+							g.Expect(target.Data["prev-tls.key"]).To(Equal(testKey("cert")))
 							g.Expect(target.Data["prev-tls.kid"]).To(Equal(generateUuid("cert")))
 						}, timeout, interval).Should(Succeed(), "controller did not produce the expected target secret within timeout")
 					})
@@ -212,8 +570,8 @@ var _ = Describe("Secret Controller", Serial, func() {
 							source,
 						)
 						Expect(err).ToNot(HaveOccurred())
-						source.Data["tls.crt"] = []byte("cert-rotation-3")
-						source.Data["tls.key"] = []byte("key-rotation-3")
+						source.Data["tls.crt"] = testCert("cert-rotation-3")
+						source.Data["tls.key"] = testKey("cert-rotation-3")
 						Expect(
 							k8sClient.Update(ctx, source),
 						).To(Succeed(), "update of source secret by test runner failed")
@@ -224,19 +582,19 @@ var _ = Describe("Secret Controller", Serial, func() {
 							g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
 								To(Succeed())
 							g.Expect(target.Data["next-tls.crt"]).
-								To(Equal([]byte("cert-rotation-3")))
+								To(Equal(testCert("cert-rotation-3")))
 							g.Expect(target.Data["next-tls.key"]).
-								To(Equal([]byte("key-rotation-3")))
+								To(Equal(testKey("cert-rotation-3")))
 							g.Expect(target.Data["next-tls.kid"]).
 								To(Equal(generateUuid("cert-rotation-3")))
-							g.Expect(target.Data["tls.crt"]).To(Equal([]byte("cert-rotation-2")))
-							g.Expect(target.Data["tls.key"]).To(Equal([]byte("key-rotation-2")))
+							g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert-rotation-2")))
+							g.Expect(target.Data["tls.key"]).To(Equal(testKey("cert-rotation-2")))
 							g.Expect(target.Data["tls.kid"]).
 								To(Equal(generateUuid("cert-rotation-2")))
 							g.Expect(target.Data["prev-tls.crt"]).
-								To(Equal([]byte("cert-rotation-1")))
+								To(Equal(testCert("cert-rotation-1")))
 							g.Expect(target.Data["prev-tls.key"]).
-								To(Equal([]byte("key-rotation-1")))
+								To(Equal(testKey("cert-rotation-1")))
 							g.Expect(target.Data["prev-tls.kid"]).
 								To(Equal(generateUuid("cert-rotation-1")))
 
@@ -246,90 +604,2062 @@ var _ = Describe("Secret Controller", Serial, func() {
 			})
 		})
 
-		Context("and the source is deleted", func() {
-			BeforeEach(func() {
-				err := k8sClient.Delete(ctx, source)
-				Expect(err).To(Succeed(), "deletion of source secret failed")
-			})
-			It("keeps the target secret", func() {
-				Eventually(func(g Gomega) {
+		Context("and a rotate request is set on the source", func() {
+			requestRotation := func(request string) {
+				err := k8sClient.Get(
+					ctx,
+					types.NamespacedName{Name: "source", Namespace: namespace},
+					source,
+				)
+				Expect(err).ToNot(HaveOccurred())
+				source.Annotations["rotator.gw.ei.telekom.de/rotate-request"] = request
+				Expect(
+					k8sClient.Update(ctx, source),
+				).To(Succeed(), "update of source secret by test runner failed")
+			}
+
+			It("performs exactly one rotation step per request", func() {
+				By("(1) requesting a rotation without changing the source", func() {
+					requestRotation("1")
+				})
+
+				By("(1) promoting next-tls and leaving it empty", func() {
+					Eventually(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Annotations).
+							To(HaveKeyWithValue("rotator.gw.ei.telekom.de/last-rotate-request", "1"))
+						g.Expect(target.Data["next-tls.crt"]).To(BeEmpty())
+						g.Expect(target.Data["next-tls.kid"]).To(BeEmpty())
+						g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+						g.Expect(target.Data["tls.kid"]).To(Equal(generateUuid("cert")))
+						g.Expect(target.Data["prev-tls.crt"]).To(BeEmpty())
+					}, timeout, interval).Should(Succeed(), "controller did not promote the target secret within timeout")
+				})
+
+				By("(1) not rotating again for the same request", func() {
 					err := k8sClient.Get(
 						ctx,
-						types.NamespacedName{Name: "target", Namespace: namespace},
-						target,
+						types.NamespacedName{Name: "source", Namespace: namespace},
+						source,
 					)
-					g.Expect(err).ShouldNot(HaveOccurred())
+					Expect(err).ToNot(HaveOccurred())
+					source.Annotations["some-new-annotation"] = "some-new-value"
+					Expect(k8sClient.Update(ctx, source)).To(Succeed())
+					Consistently(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["next-tls.crt"]).To(BeEmpty())
+						g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+					}, time.Second*2, interval).Should(Succeed(), "the target secret should not have been rotated again")
+				})
 
-					By("removing the owner reference")
-					g.Expect(target.OwnerReferences).To(BeNil())
+				By("(2) changing the source", func() {
+					err := k8sClient.Get(
+						ctx,
+						types.NamespacedName{Name: "source", Namespace: namespace},
+						source,
+					)
+					Expect(err).ToNot(HaveOccurred())
+					source.Data["tls.crt"] = testCert("cert-rotation-1")
+					source.Data["tls.key"] = testKey("cert-rotation-1")
+					Expect(
+						k8sClient.Update(ctx, source),
+					).To(Succeed(), "update of source secret by test runner failed")
+				})
 
-					By("removing the deletionTimestamp")
-					g.Expect(target.DeletionTimestamp.IsZero()).To(BeTrue())
-				}, timeout, interval).Should(Succeed(), "controller did not process target correctly")
+				By("(2) filling the empty next-tls without shifting the other slots", func() {
+					Eventually(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+						g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+						g.Expect(target.Data["prev-tls.crt"]).To(BeEmpty())
+					}, timeout, interval).Should(Succeed(), "controller did not update the target secret within timeout")
+				})
+
+				By("(3) requesting another rotation", func() {
+					requestRotation("2")
+				})
+
+				By("(3) promoting next-tls again", func() {
+					Eventually(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Annotations).
+							To(HaveKeyWithValue("rotator.gw.ei.telekom.de/last-rotate-request", "2"))
+						g.Expect(target.Data["next-tls.crt"]).To(BeEmpty())
+						g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+						g.Expect(target.Data["prev-tls.crt"]).To(Equal(testCert("cert")))
+					}, timeout, interval).Should(Succeed(), "controller did not promote the target secret within timeout")
+				})
 			})
 		})
-	})
 
-	When("a secret is created without the source and target-name annotations", func() {
-		BeforeEach(func() {
-			source = &corev1.Secret{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "some-other-secret",
-					Namespace: namespace,
-				},
-				Type: corev1.SecretTypeTLS,
-				Data: map[string][]byte{
-					"tls.crt": []byte("cert"),
-					"tls.key": []byte("key"),
-				},
-			}
-			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
-		})
-		It("does not reconcile the secret", func() {
-			Eventually(func(g Gomega) {
-				secrets := &corev1.SecretList{}
-				err := k8sClient.List(ctx, secrets, client.InNamespace(namespace))
-				Expect(err).ToNot(HaveOccurred(), "failed to list secrets")
+		Context("and the certificates are not valid at the time of the promotion", func() {
+			var crt, key []byte
+			updateSource := func(crt []byte, key []byte) {
+				err := k8sClient.Get(
+					ctx,
+					types.NamespacedName{Name: "source", Namespace: namespace},
+					source,
+				)
+				Expect(err).ToNot(HaveOccurred())
+				source.Data["tls.crt"] = crt
+				source.Data["tls.key"] = key
 				Expect(
-					secrets.Items,
-				).To(HaveLen(1), "controller should not have created a target secret")
-			}, timeout, interval).Should(Succeed())
-		})
-	})
-
-	When("a source secret is created with empty tls.key or tls.crt values", func() {
-		BeforeEach(func() {
-			source = &corev1.Secret{
-				ObjectMeta: metav1.ObjectMeta{
-					Annotations: map[string]string{
-						"rotator.gw.ei.telekom.de/source":                  "true",
-						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
-					},
-					Name:      "source",
-					Namespace: namespace,
-				},
-				Type: corev1.SecretTypeTLS,
-				Data: map[string][]byte{
-					"tls.crt": []byte(""),
-					"tls.key": []byte(""),
-				},
+					k8sClient.Update(ctx, source),
+				).To(Succeed(), "update of source secret by test runner failed")
 			}
-			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+			waitForNext := func(crt []byte) {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(crt))
+				}, timeout, interval).Should(Succeed(), "controller did not update the target secret within timeout")
+			}
+
+			BeforeEach(func() {
+				privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				crt, key = generateCertValidBetween("cert-validity", privateKey, time.Now().Add(time.Second*3),
+					time.Now().Add(time.Hour*2))
+			})
+
+			AfterEach(func() {
+				testClock.Reset()
+			})
+
+			It("delays the promotion of a certificate until it is valid", func() {
+				By("rotating a not yet valid certificate into next-tls", func() {
+					updateSource(crt, key)
+					waitForNext(crt)
+				})
+
+				By("changing the source", func() {
+					updateSource(testCert("cert-rotation-1"), testKey("cert-rotation-1"))
+				})
+
+				By("holding back the promotion until the certificate is valid", func() {
+					Consistently(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["next-tls.crt"]).To(Equal(crt))
+					}, time.Second, interval).Should(Succeed(), "the target secret should not have been rotated yet")
+					waitForNext(testCert("cert-rotation-1"))
+					Expect(target.Data["tls.crt"]).To(Equal(crt))
+				})
+			})
+
+			It("refuses to promote an expired certificate", func() {
+				By("rotating a certificate into next-tls", func() {
+					updateSource(crt, key)
+					waitForNext(crt)
+				})
+
+				By("changing the source after the certificate expired", func() {
+					testClock.SetTime(time.Now().Add(time.Hour * 3))
+					updateSource(testCert("cert-rotation-1"), testKey("cert-rotation-1"))
+				})
+
+				By("replacing the expired certificate in next-tls without promoting it", func() {
+					waitForNext(testCert("cert-rotation-1"))
+					Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+					Expect(target.Data["prev-tls.crt"]).To(BeEmpty())
+				})
+
+				By("emitting a warning event", func() {
+					Eventually(func(g Gomega) {
+						events := &eventsv1.EventList{}
+						g.Expect(k8sClient.List(ctx, events, client.InNamespace(namespace))).To(Succeed())
+						g.Expect(events.Items).To(ContainElement(SatisfyAll(
+							HaveField("Reason", "ExpiredKeyNotPromoted"),
+							HaveField("Type", corev1.EventTypeWarning),
+							HaveField("Regarding.Name", "target"),
+							HaveField("Regarding.UID", target.UID),
+						)))
+					}, timeout, interval).Should(Succeed(), "controller did not emit a warning event within timeout")
+				})
+			})
+
+			It("flags an active key that is about to expire", func() {
+				By("rotating the certificate into tls", func() {
+					updateSource(crt, key)
+					waitForNext(crt)
+					updateSource(testCert("cert-rotation-1"), testKey("cert-rotation-1"))
+					waitForNext(testCert("cert-rotation-1"))
+					Expect(target.Data["tls.crt"]).To(Equal(crt))
+				})
+
+				By("emitting a warning event", func() {
+					Eventually(func(g Gomega) {
+						events := &eventsv1.EventList{}
+						g.Expect(k8sClient.List(ctx, events, client.InNamespace(namespace))).To(Succeed())
+						g.Expect(events.Items).To(ContainElement(SatisfyAll(
+							HaveField("Reason", "ActiveKeyExpiring"),
+							HaveField("Type", corev1.EventTypeWarning),
+							HaveField("Regarding.Name", "target"),
+							HaveField("Regarding.UID", target.UID),
+						)))
+					}, timeout, interval).Should(Succeed(), "controller did not emit a warning event within timeout")
+				})
+			})
 		})
-		It("does nothing", func() {
-			Eventually(func(g Gomega) {
-				secrets := &corev1.SecretList{}
-				err := k8sClient.List(ctx, secrets, client.InNamespace(namespace))
-				Expect(err).ToNot(HaveOccurred(), "failed to list secrets")
-				Expect(
-					secrets.Items,
-				).To(HaveLen(1), "controller should not have created a target secret")
-			}, timeout, interval).Should(Succeed())
+
+		Context("and the source is changed to a key that doesn't match the certificate", func() {
+			It("refuses the source until it is fixed", func() {
+				By("changing the source to a mismatching key", func() {
+					err := k8sClient.Get(
+						ctx,
+						types.NamespacedName{Name: "source", Namespace: namespace},
+						source,
+					)
+					Expect(err).ToNot(HaveOccurred())
+					source.Data["tls.crt"] = testCert("cert-rotation-1")
+					source.Data["tls.key"] = testKey("cert-rotation-2")
+					Expect(
+						k8sClient.Update(ctx, source),
+					).To(Succeed(), "update of source secret by test runner failed")
+				})
+
+				By("annotating the source with the reason", func() {
+					Eventually(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+							To(Succeed())
+						g.Expect(source.Annotations).To(HaveKeyWithValue("rotator.gw.ei.telekom.de/invalid-source",
+							"private key in tls.key does not match the public key of the certificate in tls.crt"))
+					}, timeout, interval).Should(Succeed(), "controller did not annotate the source secret within timeout")
+				})
+
+				By("leaving the target untouched", func() {
+					Consistently(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert")))
+						g.Expect(target.Data["tls.crt"]).To(BeEmpty())
+					}, time.Second*2, interval).Should(Succeed(), "the target secret should not have been rotated")
+				})
+
+				By("fixing the key of the source", func() {
+					source.Data["tls.key"] = testKey("cert-rotation-1")
+					Expect(
+						k8sClient.Update(ctx, source),
+					).To(Succeed(), "update of source secret by test runner failed")
+				})
+
+				By("rotating the source and removing the annotation", func() {
+					Eventually(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+						g.Expect(target.Data["next-tls.key"]).To(Equal(testKey("cert-rotation-1")))
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+							To(Succeed())
+						g.Expect(source.Annotations).NotTo(HaveKey("rotator.gw.ei.telekom.de/invalid-source"))
+					}, timeout, interval).Should(Succeed(), "controller did not rotate the target secret within timeout")
+				})
+			})
 		})
-	})
 
-	When("a source secret is created without the expected fields", func() {
-		BeforeEach(func() {
+		Context("and the source is changed to a certificate that is already in the target", func() {
+			updateSource := func(crt []byte, key []byte) {
+				err := k8sClient.Get(
+					ctx,
+					types.NamespacedName{Name: "source", Namespace: namespace},
+					source,
+				)
+				Expect(err).ToNot(HaveOccurred())
+				source.Data["tls.crt"] = crt
+				source.Data["tls.key"] = key
+				Expect(
+					k8sClient.Update(ctx, source),
+				).To(Succeed(), "update of source secret by test runner failed")
+			}
+
+			It("does not rotate a certificate from an older slot again", func() {
+				By("rotating a new certificate into next-tls", func() {
+					updateSource(testCert("cert-rotation-1"), testKey("cert-rotation-1"))
+					Eventually(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+						g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+					}, timeout, interval).Should(Succeed(), "controller did not update the target secret within timeout")
+				})
+
+				By("changing the source back to the certificate in tls", func() {
+					updateSource(testCert("cert"), testKey("cert"))
+				})
+
+				By("keeping every kid in a single slot", func() {
+					Consistently(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["next-tls.kid"]).To(Equal(generateUuid("cert-rotation-1")))
+						g.Expect(target.Data["tls.kid"]).To(Equal(generateUuid("cert")))
+						g.Expect(target.Data["prev-tls.kid"]).To(BeEmpty())
+					}, time.Second*3, interval).Should(Succeed(), "the target secret should not have been rotated")
+				})
+			})
+
+			It("recognizes the same certificate with a different PEM encoding", func() {
+				crt, key := generateCert("rotation-1")
+				block, _ := pem.Decode(crt)
+
+				By("rotating the certificate into next-tls", func() {
+					updateSource(crt, key)
+					Eventually(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["next-tls.crt"]).To(Equal(crt))
+						g.Expect(target.Data["next-tls.kid"]).
+							To(Equal([]byte(uuid.NewSHA1(uuid.Nil, crt).String())))
+					}, timeout, interval).Should(Succeed(), "controller did not update the target secret within timeout")
+				})
+
+				By("re-encoding the certificate without line breaks", func() {
+					reencoded := "  -----BEGIN CERTIFICATE-----\r\n" + base64.StdEncoding.EncodeToString(block.Bytes) +
+						"\r\n-----END CERTIFICATE-----\r\n\r\n"
+					updateSource([]byte(reencoded), key)
+				})
+
+				By("not rotating the target", func() {
+					Consistently(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["next-tls.crt"]).To(Equal(crt))
+						g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+						g.Expect(target.Data["prev-tls.crt"]).To(BeEmpty())
+					}, time.Second*3, interval).Should(Succeed(), "the target secret should not have been rotated")
+				})
+			})
+		})
+
+		Context("and a kid is revoked in the target", func() {
+			BeforeEach(func() {
+				err := k8sClient.Get(
+					ctx,
+					types.NamespacedName{Name: "source", Namespace: namespace},
+					source,
+				)
+				Expect(err).ToNot(HaveOccurred())
+				source.Data["tls.crt"] = testCert("cert-rotation-1")
+				source.Data["tls.key"] = testKey("cert-rotation-1")
+				Expect(
+					k8sClient.Update(ctx, source),
+				).To(Succeed(), "update of source secret by test runner failed")
+
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+				}, timeout, interval).Should(Succeed(), "controller did not rotate the target secret within timeout")
+
+				if target.Annotations == nil {
+					target.Annotations = map[string]string{}
+				}
+				target.Annotations["rotator.gw.ei.telekom.de/revoked-kids"] = string(generateUuid("cert"))
+				Expect(k8sClient.Update(ctx, target)).To(Succeed(), "update of target secret by test runner failed")
+			})
+
+			It("purges the revoked kid from all slots", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["tls.crt"]).To(BeEmpty())
+					g.Expect(target.Data["tls.key"]).To(BeEmpty())
+					g.Expect(target.Data["tls.kid"]).To(BeEmpty())
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+				}, timeout, interval).Should(Succeed(), "controller did not purge the revoked kid within timeout")
+			})
+
+			It("emits a warning event", func() {
+				Eventually(func(g Gomega) {
+					events := &eventsv1.EventList{}
+					g.Expect(k8sClient.List(ctx, events, client.InNamespace(namespace))).To(Succeed())
+					g.Expect(events.Items).To(ContainElement(SatisfyAll(
+						HaveField("Reason", "KeyRevoked"),
+						HaveField("Type", corev1.EventTypeWarning),
+						HaveField("Regarding.Name", "target"),
+					)))
+				}, timeout, interval).Should(Succeed(), "controller did not emit a warning event within timeout")
+			})
+
+			It("refuses to rotate the revoked kid into the target again", func() {
+				By("changing the source back to the revoked certificate", func() {
+					err := k8sClient.Get(
+						ctx,
+						types.NamespacedName{Name: "source", Namespace: namespace},
+						source,
+					)
+					Expect(err).ToNot(HaveOccurred())
+					source.Data["tls.crt"] = testCert("cert")
+					source.Data["tls.key"] = testKey("cert")
+					Expect(
+						k8sClient.Update(ctx, source),
+					).To(Succeed(), "update of source secret by test runner failed")
+				})
+
+				By("not placing the revoked kid in any slot", func() {
+					time.Sleep(time.Second * 2) // give controller a chance to reconcile
+					Consistently(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+						for _, val := range target.Data {
+							g.Expect(val).NotTo(Equal(generateUuid("cert")))
+						}
+					}, time.Second*3, interval).Should(Succeed(), "the revoked kid should not have been rotated in")
+				})
+			})
+		})
+
+		Context("and a kid is revoked in the target while the source is invalid", func() {
+			BeforeEach(func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Data["tls.crt"] = []byte("test-crt")
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+						To(Succeed())
+					g.Expect(source.Annotations).To(HaveKey("rotator.gw.ei.telekom.de/invalid-source"))
+				}, timeout, interval).Should(Succeed(), "controller did not refuse the source within timeout")
+
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					metav1.SetMetaDataAnnotation(&target.ObjectMeta, "rotator.gw.ei.telekom.de/revoked-kids",
+						string(generateUuid("cert")))
+					g.Expect(k8sClient.Update(ctx, target)).To(Succeed())
+				}, timeout, interval).Should(Succeed(), "update of target secret by test runner failed")
+			})
+
+			It("purges the revoked kid from the slots and the JWK set", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.crt"]).To(BeEmpty())
+					g.Expect(target.Data["next-tls.kid"]).To(BeEmpty())
+					g.Expect(string(target.Data["jwks.json"])).NotTo(ContainSubstring(string(generateUuid("cert"))))
+				}, timeout, interval).Should(Succeed(), "controller did not purge the revoked kid within timeout")
+			})
+		})
+
+		Context("and the target is paused", func() {
+			BeforeEach(func() {
+				if target.Annotations == nil {
+					target.Annotations = map[string]string{}
+				}
+				target.Annotations["rotator.gw.ei.telekom.de/paused"] = "true"
+				Expect(k8sClient.Update(ctx, target)).To(Succeed(), "update of target secret by test runner failed")
+
+				err := k8sClient.Get(
+					ctx,
+					types.NamespacedName{Name: "source", Namespace: namespace},
+					source,
+				)
+				Expect(err).ToNot(HaveOccurred())
+				source.Data["tls.crt"] = testCert("cert-rotation-1")
+				source.Data["tls.key"] = testKey("cert-rotation-1")
+				Expect(
+					k8sClient.Update(ctx, source),
+				).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			It("holds back the source change until the target is resumed", func() {
+				By("not rotating the values while paused", func() {
+					Consistently(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert")))
+						g.Expect(target.Data["tls.crt"]).To(BeEmpty())
+					}, time.Second*3, interval).Should(Succeed(), "the target secret should not have been rotated")
+				})
+
+				By("emitting an event about the paused rotation", func() {
+					Eventually(func(g Gomega) {
+						events := &eventsv1.EventList{}
+						g.Expect(k8sClient.List(ctx, events, client.InNamespace(namespace))).To(Succeed())
+						g.Expect(events.Items).To(ContainElement(SatisfyAll(
+							HaveField("Reason", "RotationPaused"),
+							HaveField("Regarding.Name", "target"),
+						)))
+					}, timeout, interval).Should(Succeed(), "controller did not emit an event within timeout")
+				})
+
+				By("resuming the target", func() {
+					Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					delete(target.Annotations, "rotator.gw.ei.telekom.de/paused")
+					Expect(k8sClient.Update(ctx, target)).To(Succeed(), "update of target secret by test runner failed")
+				})
+
+				By("applying the queued change once", func() {
+					Eventually(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+						g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+						g.Expect(target.Data["prev-tls.crt"]).To(BeEmpty())
+					}, timeout, interval).Should(Succeed(), "controller did not rotate the target secret within timeout")
+					Consistently(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+					}, time.Second*2, interval).Should(Succeed(), "the target secret should have been rotated only once")
+				})
+			})
+		})
+
+		Context("and the source is deleted", func() {
+			BeforeEach(func() {
+				err := k8sClient.Delete(ctx, source)
+				Expect(err).To(Succeed(), "deletion of source secret failed")
+			})
+			It("keeps the target secret", func() {
+				Eventually(func(g Gomega) {
+					err := k8sClient.Get(
+						ctx,
+						types.NamespacedName{Name: "target", Namespace: namespace},
+						target,
+					)
+					g.Expect(err).ShouldNot(HaveOccurred())
+
+					By("removing the owner reference")
+					g.Expect(target.OwnerReferences).To(BeNil())
+
+					By("removing the deletionTimestamp")
+					g.Expect(target.DeletionTimestamp.IsZero()).To(BeTrue())
+				}, timeout, interval).Should(Succeed(), "controller did not process target correctly")
+			})
+		})
+
+		Context("and the source is deleted after its key material became unreadable", func() {
+			BeforeEach(func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				Expect(source.Finalizers).To(ContainElement("rotator.gw.ei.telekom.de/finalizer"))
+				source.Annotations["rotator.gw.ei.telekom.de/source-format"] = "pkcs12"
+				source.Annotations["rotator.gw.ei.telekom.de/password-secret"] = "missing-password"
+				source.Data["keystore.p12"] = []byte("not a keystore")
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+				Expect(k8sClient.Delete(ctx, source)).To(Succeed(), "deletion of source secret failed")
+			})
+
+			It("removes the finalizer and keeps the target secret", func() {
+				Eventually(func(g Gomega) {
+					err := k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)
+					g.Expect(errors.IsNotFound(err)).To(BeTrue(), "source secret should have been deleted")
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.OwnerReferences).To(BeNil())
+				}, timeout, interval).Should(Succeed(), "controller did not remove the finalizer within timeout")
+			})
+		})
+
+		Context("and the source annotation is removed", func() {
+			BeforeEach(func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				Expect(source.Finalizers).To(ContainElement("rotator.gw.ei.telekom.de/finalizer"))
+				delete(source.Annotations, "rotator.gw.ei.telekom.de/source")
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			It("releases the target secret and does not enroll the former source again", func() {
+				By("removing the owner reference of the target and the finalizer of the source", func() {
+					Eventually(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.OwnerReferences).To(BeNil())
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+							To(Succeed())
+						g.Expect(source.Finalizers).To(BeEmpty())
+					}, timeout, interval).Should(Succeed(), "controller did not release the target within timeout")
+				})
+
+				By("ignoring changes of the former source and its target", func() {
+					source.Data["tls.crt"] = testCert("cert-rotation-1")
+					source.Data["tls.key"] = testKey("cert-rotation-1")
+					Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+					metav1.SetMetaDataAnnotation(&target.ObjectMeta, "some-new-annotation", "some-new-value")
+					Expect(k8sClient.Update(ctx, target)).To(Succeed(), "update of target secret by test runner failed")
+
+					Consistently(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert")))
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+							To(Succeed())
+						g.Expect(source.Finalizers).To(BeEmpty())
+					}, time.Second*2, interval).Should(Succeed(), "the former source should not be enrolled again")
+				})
+			})
+		})
+	})
+
+	When("a secret that is no source controls another secret", func() {
+		It("ignores the owner", func() {
+			owner := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: namespace}}
+			Expect(k8sClient.Create(ctx, owner)).To(Succeed(), "creation of owner secret failed")
+			ownerRef := metav1.NewControllerRef(owner, corev1.SchemeGroupVersion.WithKind("Secret"))
+			owned := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:            "owned",
+				Namespace:       namespace,
+				OwnerReferences: []metav1.OwnerReference{*ownerRef},
+			}}
+			Expect(k8sClient.Create(ctx, owned)).To(Succeed(), "creation of owned secret failed")
+			metav1.SetMetaDataAnnotation(&owned.ObjectMeta, "some-new-annotation", "some-new-value")
+			Expect(k8sClient.Update(ctx, owned)).To(Succeed(), "update of owned secret by test runner failed")
+
+			Consistently(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(owner), owner)).To(Succeed())
+				g.Expect(owner.Finalizers).To(BeEmpty())
+			}, time.Second*2, interval).Should(Succeed(), "controller should not have added its finalizer to the owner")
+		})
+	})
+
+	When("a source secret with a min-next-age annotation is created", func() {
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/min-next-age":            "5s",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+
+			// wait for the target secret to be created
+			Eventually(func(g Gomega) {
+				err := k8sClient.Get(
+					ctx,
+					types.NamespacedName{Name: "target", Namespace: namespace},
+					target,
+				)
+				g.Expect(err).ShouldNot(HaveOccurred())
+			}, timeout, interval).Should(Succeed(), "controller did not create target secret within timeout")
+		})
+
+		It("records when next-tls was published", func() {
+			Expect(target.Annotations).To(HaveKey("rotator.gw.ei.telekom.de/next-tls-published-at"))
+			_, err := time.Parse(time.RFC3339, target.Annotations["rotator.gw.ei.telekom.de/next-tls-published-at"])
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("holds back the rotation until next-tls has been published for min-next-age", func() {
+			By("changing the source right after the target was created", func() {
+				err := k8sClient.Get(
+					ctx,
+					types.NamespacedName{Name: "source", Namespace: namespace},
+					source,
+				)
+				Expect(err).ToNot(HaveOccurred())
+				source.Data["tls.crt"] = testCert("cert-rotation-1")
+				source.Data["tls.key"] = testKey("cert-rotation-1")
+				Expect(
+					k8sClient.Update(ctx, source),
+				).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("not rotating the values before min-next-age has passed", func() {
+				Consistently(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert")))
+					g.Expect(target.Data["tls.crt"]).To(BeEmpty())
+				}, time.Second*2, interval).Should(Succeed(), "the target secret should not have been rotated yet")
+			})
+
+			By("rotating the values once min-next-age has passed", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+					g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+				}, timeout, interval).Should(Succeed(), "controller did not rotate the target secret within timeout")
+			})
+		})
+	})
+
+	When("a source secret with a retained-keys annotation is created", func() {
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/retained-keys":           "3",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+
+			// wait for the target secret to be created
+			Eventually(func(g Gomega) {
+				err := k8sClient.Get(
+					ctx,
+					types.NamespacedName{Name: "target", Namespace: namespace},
+					target,
+				)
+				g.Expect(err).ShouldNot(HaveOccurred())
+			}, timeout, interval).Should(Succeed(), "controller did not create target secret within timeout")
+		})
+
+		It("creates numbered previous slots", func() {
+			for _, slot := range []string{"prev-1-tls", "prev-2-tls", "prev-3-tls"} {
+				Expect(target.Data).To(HaveKeyWithValue(slot+".crt", BeEmpty()))
+				Expect(target.Data).To(HaveKeyWithValue(slot+".key", BeEmpty()))
+				Expect(target.Data).To(HaveKeyWithValue(slot+".kid", BeEmpty()))
+			}
+			Expect(target.Data).NotTo(HaveKey("prev-tls.crt"))
+		})
+
+		It("shifts the whole ring and drops the oldest key", func() {
+			for i := 1; i <= 5; i++ {
+				crt := fmt.Sprintf("cert-rotation-%d", i)
+				By("changing the source to "+crt, func() {
+					err := k8sClient.Get(
+						ctx,
+						types.NamespacedName{Name: "source", Namespace: namespace},
+						source,
+					)
+					Expect(err).ToNot(HaveOccurred())
+					source.Data["tls.crt"] = testCert(crt)
+					source.Data["tls.key"] = testKey(crt)
+					Expect(
+						k8sClient.Update(ctx, source),
+					).To(Succeed(), "update of source secret by test runner failed")
+				})
+
+				By("waiting for "+crt+" to be rotated into next-tls", func() {
+					Eventually(func(g Gomega) {
+						g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+							To(Succeed())
+						g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert(crt)))
+					}, timeout, interval).Should(Succeed(), "controller did not rotate the target secret within timeout")
+				})
+			}
+
+			By("keeping the three previous keys in order", func() {
+				Expect(target.Data["tls.crt"]).To(Equal(testCert("cert-rotation-4")))
+				Expect(target.Data["prev-1-tls.crt"]).To(Equal(testCert("cert-rotation-3")))
+				Expect(target.Data["prev-1-tls.key"]).To(Equal(testKey("cert-rotation-3")))
+				Expect(target.Data["prev-1-tls.kid"]).To(Equal(generateUuid("cert-rotation-3")))
+				Expect(target.Data["prev-2-tls.crt"]).To(Equal(testCert("cert-rotation-2")))
+				Expect(target.Data["prev-3-tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+			})
+
+			By("dropping older keys", func() {
+				Expect(target.Data).NotTo(HaveKey("prev-4-tls.crt"))
+				for _, val := range target.Data {
+					Expect(val).NotTo(Equal(testCert("cert")))
+				}
+			})
+		})
+	})
+
+	When("a source secret with a history-size annotation is created", func() {
+		rotateSource := func(name string) {
+			err := k8sClient.Get(
+				ctx,
+				types.NamespacedName{Name: "source", Namespace: namespace},
+				source,
+			)
+			Expect(err).ToNot(HaveOccurred())
+			source.Data["tls.crt"] = testCert(name)
+			source.Data["tls.key"] = testKey(name)
+			Expect(
+				k8sClient.Update(ctx, source),
+			).To(Succeed(), "update of source secret by test runner failed")
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+				g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert(name)))
+			}, timeout, interval).Should(Succeed(), "controller did not rotate the target secret within timeout")
+		}
+
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/history-size":            "2",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+
+			// wait for the target secret to be created
+			Eventually(func(g Gomega) {
+				err := k8sClient.Get(
+					ctx,
+					types.NamespacedName{Name: "target", Namespace: namespace},
+					target,
+				)
+				g.Expect(err).ShouldNot(HaveOccurred())
+			}, timeout, interval).Should(Succeed(), "controller did not create target secret within timeout")
+
+			rotateSource("cert-rotation-1")
+			rotateSource("cert-rotation-2")
+		})
+
+		It("records the previous slot contents in a history secret", func() {
+			history := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target-history", Namespace: namespace}, history)).
+				To(Succeed())
+			Expect(history.Data["1.next-tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+			Expect(history.Data["1.tls.crt"]).To(Equal(testCert("cert")))
+			Expect(history.Data["2.next-tls.crt"]).To(Equal(testCert("cert")))
+			Expect(history.Data["2.tls.crt"]).To(BeEmpty())
+			Expect(history.Data).NotTo(HaveKey("1.jwks.json"), "the outputs should not have been recorded")
+			Expect(history.OwnerReferences).To(HaveLen(1))
+			Expect(history.OwnerReferences[0].Name).To(Equal("source"))
+		})
+
+		It("does not record forced rotations that leave the slots unchanged", func() {
+			requestRotation := func(request string) {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Annotations["rotator.gw.ei.telekom.de/rotate-request"] = request
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Annotations).
+						To(HaveKeyWithValue("rotator.gw.ei.telekom.de/last-rotate-request", request))
+				}, timeout, interval).Should(Succeed(), "controller did not handle the rotate request within timeout")
+			}
+
+			By("promoting next-tls.* as requested", func() {
+				requestRotation("1")
+				Expect(target.Data["tls.crt"]).To(Equal(testCert("cert-rotation-2")))
+			})
+
+			By("requesting another rotation with nothing to promote", func() {
+				requestRotation("2")
+			})
+
+			By("keeping the snapshot from before the promotion as the newest one", func() {
+				history := &corev1.Secret{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target-history", Namespace: namespace}, history)).
+					To(Succeed())
+				Expect(history.Data["1.next-tls.crt"]).To(Equal(testCert("cert-rotation-2")))
+				Expect(history.Data["1.tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+			})
+		})
+
+		Context("and a rollback is requested", func() {
+			BeforeEach(func() {
+				err := k8sClient.Get(
+					ctx,
+					types.NamespacedName{Name: "source", Namespace: namespace},
+					source,
+				)
+				Expect(err).ToNot(HaveOccurred())
+				source.Annotations["rotator.gw.ei.telekom.de/rollback-request"] = "1"
+				Expect(
+					k8sClient.Update(ctx, source),
+				).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			It("restores the slots from before the last rotation", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Annotations).
+						To(HaveKeyWithValue("rotator.gw.ei.telekom.de/last-rollback-request", "1"))
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+					g.Expect(target.Data["next-tls.key"]).To(Equal(testKey("cert-rotation-1")))
+					g.Expect(target.Data["next-tls.kid"]).To(Equal(generateUuid("cert-rotation-1")))
+					g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+					g.Expect(target.Data["prev-tls.crt"]).To(BeEmpty())
+				}, timeout, interval).Should(Succeed(), "controller did not roll back the target secret within timeout")
+			})
+
+			It("does not rotate the rolled back source in again", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Annotations).
+						To(HaveKeyWithValue("rotator.gw.ei.telekom.de/blocked-kids", string(generateUuid("cert-rotation-2"))))
+				}, timeout, interval).Should(Succeed(), "controller did not roll back the target secret within timeout")
+
+				Consistently(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+				}, time.Second*3, interval).Should(Succeed(), "the rolled back source should not have been rotated in again")
+			})
+
+			It("rotates again once the source changes", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Annotations).
+						To(HaveKeyWithValue("rotator.gw.ei.telekom.de/last-rollback-request", "1"))
+				}, timeout, interval).Should(Succeed(), "controller did not roll back the target secret within timeout")
+
+				rotateSource("cert-rotation-3")
+				Expect(target.Data["tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+				Expect(target.Data["prev-tls.crt"]).To(Equal(testCert("cert")))
+			})
+		})
+	})
+
+	When("a secret with the name of the history secret exists that is not controlled by the source", func() {
+		history := &corev1.Secret{}
+
+		BeforeEach(func() {
+			history = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "target-history",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeOpaque,
+				Data: map[string][]byte{"config": []byte("user data")},
+			}
+			Expect(k8sClient.Create(ctx, history)).To(Succeed(), "creation of history secret failed")
+
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/history-size":            "2",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+
+		It("refuses to record the history in it", func() {
+			By("emitting a warning event", func() {
+				Eventually(func(g Gomega) {
+					events := &eventsv1.EventList{}
+					g.Expect(k8sClient.List(ctx, events, client.InNamespace(namespace))).To(Succeed())
+					g.Expect(events.Items).To(ContainElement(SatisfyAll(
+						HaveField("Reason", "HistoryConflict"),
+						HaveField("Type", corev1.EventTypeWarning),
+						HaveField("Regarding.UID", source.UID),
+					)))
+				}, timeout, interval).Should(Succeed(), "controller did not emit a warning event within timeout")
+			})
+
+			By("leaving the secret untouched", func() {
+				Consistently(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(history), history)).To(Succeed())
+					g.Expect(history.Data).To(Equal(map[string][]byte{"config": []byte("user data")}))
+					g.Expect(history.OwnerReferences).To(BeEmpty())
+				}, time.Second*2, interval).Should(Succeed())
+			})
+		})
+	})
+
+	When("a paused source secret is created", func() {
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/paused":                  "true",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+
+		It("creates the target secret only once the source is resumed", func() {
+			By("not creating the target while paused", func() {
+				Consistently(func(g Gomega) {
+					err := k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)
+					g.Expect(errors.IsNotFound(err)).To(BeTrue(), "target secret should not have been created")
+				}, time.Second*3, interval).Should(Succeed())
+			})
+
+			By("resuming the source", func() {
+				err := k8sClient.Get(
+					ctx,
+					types.NamespacedName{Name: "source", Namespace: namespace},
+					source,
+				)
+				Expect(err).ToNot(HaveOccurred())
+				source.Annotations["rotator.gw.ei.telekom.de/paused"] = "false"
+				Expect(
+					k8sClient.Update(ctx, source),
+				).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("creating the target", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert")))
+				}, timeout, interval).Should(Succeed(), "controller did not create target secret within timeout")
+			})
+		})
+	})
+
+	When("a secret is created without the source and target-name annotations", func() {
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "some-other-secret",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+		It("does not reconcile the secret", func() {
+			Eventually(func(g Gomega) {
+				secrets := &corev1.SecretList{}
+				err := k8sClient.List(ctx, secrets, client.InNamespace(namespace))
+				Expect(err).ToNot(HaveOccurred(), "failed to list secrets")
+				Expect(
+					secrets.Items,
+				).To(HaveLen(1), "controller should not have created a target secret")
+			}, timeout, interval).Should(Succeed())
+		})
+	})
+
+	When("a source secret is created with an invalid certificate", func() {
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": []byte("test-crt"),
+					"tls.key": []byte("test-key"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+
+		It("refuses the source", func() {
+			By("annotating the source with the reason", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+						To(Succeed())
+					g.Expect(source.Annotations).To(HaveKeyWithValue("rotator.gw.ei.telekom.de/invalid-source",
+						"tls.crt does not contain a PEM encoded certificate"))
+				}, timeout, interval).Should(Succeed(), "controller did not annotate the source secret within timeout")
+			})
+
+			By("emitting a warning event", func() {
+				Eventually(func(g Gomega) {
+					events := &eventsv1.EventList{}
+					g.Expect(k8sClient.List(ctx, events, client.InNamespace(namespace))).To(Succeed())
+					g.Expect(events.Items).To(ContainElement(SatisfyAll(
+						HaveField("Reason", "InvalidSource"),
+						HaveField("Type", corev1.EventTypeWarning),
+						HaveField("Regarding.Name", "source"),
+					)))
+				}, timeout, interval).Should(Succeed(), "controller did not emit a warning event within timeout")
+			})
+
+			By("not creating the target secret", func() {
+				Consistently(func(g Gomega) {
+					err := k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)
+					g.Expect(errors.IsNotFound(err)).To(BeTrue(), "target secret should not have been created")
+				}, time.Second*2, interval).Should(Succeed())
+			})
+		})
+	})
+
+	When("a source secret is created with empty tls.key or tls.crt values", func() {
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": []byte(""),
+					"tls.key": []byte(""),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+		It("does nothing", func() {
+			Eventually(func(g Gomega) {
+				secrets := &corev1.SecretList{}
+				err := k8sClient.List(ctx, secrets, client.InNamespace(namespace))
+				Expect(err).ToNot(HaveOccurred(), "failed to list secrets")
+				Expect(
+					secrets.Items,
+				).To(HaveLen(1), "controller should not have created a target secret")
+			}, timeout, interval).Should(Succeed())
+		})
+	})
+
+	When("a source secret is created without the expected fields", func() {
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Data: map[string][]byte{
+					"other-key": []byte("other-val"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+		It("does nothing", func() {
+			Eventually(func(g Gomega) {
+				secrets := &corev1.SecretList{}
+				err := k8sClient.List(ctx, secrets, client.InNamespace(namespace))
+				Expect(err).ToNot(HaveOccurred(), "failed to list secrets")
+				Expect(
+					secrets.Items,
+				).To(HaveLen(1), "controller should not have created a target secret")
+			}, timeout, interval).Should(Succeed())
+		})
+	})
+
+	When("a source secret with the jwk-thumbprint kid strategy is created", func() {
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/kid-strategy":            "jwk-thumbprint",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+			}, timeout, interval).Should(Succeed(), "controller did not create the target secret within timeout")
+		})
+
+		It("uses the JWK thumbprint of the key as kid and records the strategy", func() {
+			Expect(target.Data["next-tls.kid"]).To(Equal([]byte(jwkThumbprint("cert"))))
+			Expect(target.Annotations).To(HaveKeyWithValue("rotator.gw.ei.telekom.de/kid-strategy", "jwk-thumbprint"))
+		})
+
+		It("keeps the kid and slot when the certificate is renewed with the same key", func() {
+			block, _ := pem.Decode(testKey("cert"))
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			Expect(err).NotTo(HaveOccurred())
+			renewed, _ := generateCertForKey("cert-renewed", key.(*ecdsa.PrivateKey))
+
+			By("renewing the certificate of the source", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Data["tls.crt"] = renewed
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("replacing the certificate in next-tls without rotating", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(renewed))
+					g.Expect(target.Data["next-tls.kid"]).To(Equal([]byte(jwkThumbprint("cert"))))
+					g.Expect(target.Data["tls.crt"]).To(BeEmpty())
+				}, timeout, interval).Should(Succeed(), "controller did not renew the certificate within timeout")
+			})
+		})
+	})
+
+	When("a source secret with an explicit kid is created", func() {
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/kid-strategy":            "explicit",
+						"rotator.gw.ei.telekom.de/kid":                     "signing-key-1",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+
+		It("uses the kid of the annotation", func() {
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+				g.Expect(target.Data["next-tls.kid"]).To(Equal([]byte("signing-key-1")))
+				g.Expect(target.Annotations).To(HaveKeyWithValue("rotator.gw.ei.telekom.de/kid-strategy", "explicit"))
+			}, timeout, interval).Should(Succeed(), "controller did not create the target secret within timeout")
+		})
+	})
+
+	When("a source secret with a certificate chain is created", func() {
+		var crt, key []byte
+		var ders [][]byte
+
+		BeforeEach(func() {
+			crt, key, ders = generateChain("leaf")
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": crt,
+					"tls.key": key,
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+			}, timeout, interval).Should(Succeed(), "controller did not create the target secret within timeout")
+		})
+
+		It("stores the leaf, chain and x5c next to the certificate", func() {
+			By("copying the certificate with its chain verbatim", func() {
+				Expect(target.Data["next-tls.crt"]).To(Equal(crt))
+			})
+
+			By("storing the leaf certificate without the chain", func() {
+				Expect(target.Data["next-tls.leaf"]).
+					To(Equal(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ders[0]})))
+			})
+
+			By("storing the intermediate certificate as chain", func() {
+				Expect(target.Data["next-tls.chain"]).
+					To(Equal(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ders[1]})))
+			})
+
+			By("publishing the leaf followed by the intermediate as x5c", func() {
+				Expect(target.Data["next-tls.x5c"]).To(MatchJSON(fmt.Sprintf("[%q,%q]",
+					base64.StdEncoding.EncodeToString(ders[0]), base64.StdEncoding.EncodeToString(ders[1]))))
+			})
+		})
+
+		It("updates the chain of the slot when only the chain changes", func() {
+			kid := target.Data["next-tls.kid"]
+			leaf := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ders[0]})
+
+			By("removing the intermediate certificate from the source", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Data["tls.crt"] = leaf
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("replacing the chain in the same slot, keeping its kid", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(leaf))
+					g.Expect(target.Data["next-tls.leaf"]).To(Equal(leaf))
+					g.Expect(target.Data["next-tls.chain"]).To(BeEmpty())
+					g.Expect(target.Data["next-tls.x5c"]).
+						To(MatchJSON(fmt.Sprintf("[%q]", base64.StdEncoding.EncodeToString(ders[0]))))
+					g.Expect(target.Data["next-tls.kid"]).To(Equal(kid))
+					g.Expect(target.Data["tls.crt"]).To(BeEmpty())
+				}, timeout, interval).Should(Succeed(), "controller did not update the chain within timeout")
+			})
+		})
+	})
+
+	When("a source secret with a public JWK set config map is created", func() {
+		cm := &corev1.ConfigMap{}
+
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/jwks-configmap-name":     "public-jwks",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "public-jwks", Namespace: namespace}, cm)).
+					To(Succeed())
+			}, timeout, interval).Should(Succeed(), "controller did not create the config map within timeout")
+		})
+
+		AfterEach(func() {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, cm))).
+				To(Succeed(), "deletion of public JWK set config map failed")
+		})
+
+		It("keeps the public JWK set of the target in the config map", func() {
+			By("publishing the JWK set of the target, owned by the source", func() {
+				Expect(cm.Data["jwks.json"]).To(Equal(string(target.Data["jwks.json"])))
+				Expect(cm.OwnerReferences).To(HaveLen(1))
+				Expect(cm.OwnerReferences[0].Name).To(Equal("source"))
+				Expect(cm.OwnerReferences[0].Kind).To(Equal("Secret"))
+			})
+
+			By("changing the source", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Data["tls.crt"] = testCert("cert-rotation-1")
+				source.Data["tls.key"] = testKey("cert-rotation-1")
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("updating the config map with the rotated JWK set", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["tls.kid"]).To(Equal(generateUuid("cert")))
+					g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cm), cm)).To(Succeed())
+					g.Expect(cm.Data["jwks.json"]).To(Equal(string(target.Data["jwks.json"])))
+				}, timeout, interval).Should(Succeed(), "controller did not update the config map within timeout")
+			})
+
+			By("repairing a modified config map", func() {
+				cm.Data["jwks.json"] = `{"keys":[]}`
+				Expect(k8sClient.Update(ctx, cm)).To(Succeed(), "update of config map by test runner failed")
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cm), cm)).To(Succeed())
+					g.Expect(cm.Data["jwks.json"]).To(Equal(string(target.Data["jwks.json"])))
+				}, timeout, interval).Should(Succeed(), "controller did not repair the config map within timeout")
+			})
+		})
+
+		It("repairs and releases config maps while the source is paused", func() {
+			renamed := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "public-jwks-renamed", Namespace: namespace},
+			}
+			DeferCleanup(func() {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, renamed))).
+					To(Succeed(), "deletion of renamed config map failed")
+			})
+
+			By("pausing the source", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Annotations["rotator.gw.ei.telekom.de/paused"] = "true"
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("repairing a modified config map", func() {
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cm), cm)).To(Succeed())
+				cm.Data["jwks.json"] = `{"keys":[]}`
+				cm.Data["other"] = "value"
+				Expect(k8sClient.Update(ctx, cm)).To(Succeed(), "update of config map by test runner failed")
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cm), cm)).To(Succeed())
+					g.Expect(cm.Data["jwks.json"]).To(Equal(string(target.Data["jwks.json"])))
+				}, timeout, interval).Should(Succeed(), "controller did not repair the config map within timeout")
+			})
+
+			By("renaming the config map", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Annotations["rotator.gw.ei.telekom.de/jwks-configmap-name"] = "public-jwks-renamed"
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("publishing the JWK set in the renamed config map and releasing the former one", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(renamed), renamed)).To(Succeed())
+					g.Expect(renamed.Data["jwks.json"]).To(Equal(string(target.Data["jwks.json"])))
+					g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cm), cm)).To(Succeed())
+					g.Expect(cm.Data).To(Equal(map[string]string{"other": "value"}))
+					g.Expect(cm.OwnerReferences).To(BeEmpty())
+				}, timeout, interval).Should(Succeed(), "controller did not rename the config map within timeout")
+			})
+
+			By("removing the config map annotation", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				delete(source.Annotations, "rotator.gw.ei.telekom.de/jwks-configmap-name")
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("deleting the former config map without other data", func() {
+				Eventually(func(g Gomega) {
+					err := k8sClient.Get(ctx, client.ObjectKeyFromObject(renamed), renamed)
+					g.Expect(errors.IsNotFound(err)).To(BeTrue(), "renamed config map should have been deleted")
+				}, timeout, interval).Should(Succeed(), "controller did not delete the config map within timeout")
+			})
+		})
+	})
+
+	When("a source secret with keystore outputs is created", func() {
+		BeforeEach(func() {
+			password := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "keystore-password", Namespace: namespace},
+				Data:       map[string][]byte{"password": []byte("changeit")},
+			}
+			Expect(k8sClient.Create(ctx, password)).To(Succeed(), "creation of password secret failed")
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                   "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name":  "target",
+						"rotator.gw.ei.telekom.de/keystores":                "pkcs12,jks",
+						"rotator.gw.ei.telekom.de/keystore-password-secret": "keystore-password",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+			}, timeout, interval).Should(Succeed(), "controller did not create the target secret within timeout")
+		})
+
+		It("writes the keys of the slots into keystores", func() {
+			By("writing a PKCS#12 keystore protected by the password", func() {
+				key, certs, err := keystore.DecodePKCS12(target.Data["keystore.p12"], "changeit")
+				Expect(err).NotTo(HaveOccurred())
+				block, _ := pem.Decode(testKey("cert"))
+				sourceKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(sourceKey.(*ecdsa.PrivateKey).Equal(key)).To(BeTrue())
+				Expect(certs).To(HaveLen(1))
+				Expect(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certs[0].Raw})).
+					To(Equal(testCert("cert")))
+			})
+
+			By("writing a JKS keystore", func() {
+				Expect(target.Data["keystore.jks"]).To(HavePrefix(string([]byte{0xfe, 0xed, 0xfe, 0xed})))
+			})
+
+			By("not updating the target again while the slots are unchanged", func() {
+				resourceVersion := target.ResourceVersion
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Annotations["trigger"] = "reconcile"
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+				Consistently(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.ResourceVersion).To(Equal(resourceVersion))
+				}, time.Second*2, interval).Should(Succeed())
+			})
+
+			By("removing the keystores once they are no longer configured", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				delete(source.Annotations, "rotator.gw.ei.telekom.de/keystores")
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data).NotTo(HaveKey("keystore.p12"))
+					g.Expect(target.Data).NotTo(HaveKey("keystore.jks"))
+				}, timeout, interval).Should(Succeed(), "controller did not remove the keystores within timeout")
+			})
+		})
+
+		It("keeps rotating the slots when the password secret is missing", func() {
+			By("deleting the password secret and changing the source", func() {
+				password := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "keystore-password", Namespace: namespace}}
+				Expect(k8sClient.Delete(ctx, password)).To(Succeed(), "deletion of password secret failed")
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Data["tls.crt"] = testCert("cert-rotation-1")
+				source.Data["tls.key"] = testKey("cert-rotation-1")
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("rotating the source into the target and removing the stale keystores", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+					g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+					g.Expect(target.Data).NotTo(HaveKey("keystore.p12"))
+					g.Expect(target.Data).NotTo(HaveKey("keystore.jks"))
+				}, timeout, interval).Should(Succeed(), "controller did not rotate the target secret within timeout")
+			})
+
+			By("emitting a warning event", func() {
+				Eventually(func(g Gomega) {
+					events := &eventsv1.EventList{}
+					g.Expect(k8sClient.List(ctx, events, client.InNamespace(namespace))).To(Succeed())
+					g.Expect(events.Items).To(ContainElement(SatisfyAll(
+						HaveField("Reason", "KeystoreFailed"),
+						HaveField("Type", corev1.EventTypeWarning),
+						HaveField("Regarding.Name", "source"),
+					)))
+				}, timeout, interval).Should(Succeed(), "controller did not emit a warning event within timeout")
+			})
+		})
+	})
+
+	When("the JWK set of a target secret is requested from the JWKS endpoint", func() {
+		var server http.Handler
+
+		BeforeEach(func() {
+			server = (&controller.JWKSServer{Reader: k8sClient, MaxAge: time.Hour}).Handler()
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/min-next-age":            "10m",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+			}, timeout, interval).Should(Succeed(), "controller did not create the target secret within timeout")
+		})
+
+		It("serves the public JWK set with caching headers", func() {
+			var etag string
+
+			By("responding with jwks.json of the target", func() {
+				recorder := httptest.NewRecorder()
+				server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/jwks/"+namespace+"/target", nil))
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Body.Bytes()).To(Equal(target.Data["jwks.json"]))
+				Expect(recorder.Header().Get("Content-Type")).To(Equal("application/jwk-set+json"))
+				etag = recorder.Header().Get("ETag")
+				Expect(etag).NotTo(BeEmpty())
+			})
+
+			By("deriving the max-age from the min-next-age of the source", func() {
+				recorder := httptest.NewRecorder()
+				server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/jwks/"+namespace+"/target", nil))
+				Expect(recorder.Header().Get("Cache-Control")).To(Equal("public, max-age=300"))
+			})
+
+			By("responding with not modified to a matching If-None-Match", func() {
+				recorder := httptest.NewRecorder()
+				request := httptest.NewRequest(http.MethodGet, "/jwks/"+namespace+"/target", nil)
+				request.Header.Set("If-None-Match", etag)
+				server.ServeHTTP(recorder, request)
+				Expect(recorder.Code).To(Equal(http.StatusNotModified))
+				Expect(recorder.Body.Bytes()).To(BeEmpty())
+			})
+
+			By("not serving secrets that are not managed targets", func() {
+				recorder := httptest.NewRecorder()
+				server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/jwks/"+namespace+"/source", nil))
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+
+				recorder = httptest.NewRecorder()
+				server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/jwks/"+namespace+"/missing", nil))
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			})
+
+			By("not serving secrets that only carry the annotations of a target", func() {
+				impostor := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"rotator.gw.ei.telekom.de/source-uid": string(source.UID),
+						},
+						Name:      "impostor",
+						Namespace: namespace,
+					},
+					Data: map[string][]byte{
+						"jwks.json": target.Data["jwks.json"],
+					},
+				}
+				Expect(k8sClient.Create(ctx, impostor)).To(Succeed(), "creation of impostor secret failed")
+
+				recorder := httptest.NewRecorder()
+				server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/jwks/"+namespace+"/impostor", nil))
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+
+	When("a source secret with an OpenID Connect issuer is created", func() {
+		cm := &corev1.ConfigMap{}
+
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/jwks-configmap-name":     "public-jwks",
+						"rotator.gw.ei.telekom.de/oidc-issuer":             "https://issuer.example.com",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "public-jwks", Namespace: namespace}, cm)).
+					To(Succeed())
+			}, timeout, interval).Should(Succeed(), "controller did not create the config map within timeout")
+		})
+
+		AfterEach(func() {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, cm))).
+				To(Succeed(), "deletion of public JWK set config map failed")
+		})
+
+		It("keeps the OpenID Connect discovery document consistent with the slots", func() {
+			By("writing the metadata into the target and the config map", func() {
+				metadata := `{"issuer":"https://issuer.example.com",` +
+					`"jwks_uri":"https://issuer.example.com/.well-known/jwks.json",` +
+					`"response_types_supported":["id_token"],"subject_types_supported":["public"],` +
+					`"id_token_signing_alg_values_supported":["ES256"]}`
+				Expect(target.Data["openid-configuration"]).To(MatchJSON(metadata))
+				Expect(cm.Data["openid-configuration"]).To(MatchJSON(metadata))
+			})
+
+			By("serving the metadata from the JWKS endpoint", func() {
+				server := (&controller.JWKSServer{Reader: k8sClient, MaxAge: time.Hour}).Handler()
+				recorder := httptest.NewRecorder()
+				server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet,
+					"/jwks/"+namespace+"/target/.well-known/openid-configuration", nil))
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Body.Bytes()).To(Equal(target.Data["openid-configuration"]))
+				Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+
+				recorder = httptest.NewRecorder()
+				server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet,
+					"/jwks/"+namespace+"/target/.well-known/jwks.json", nil))
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Body.Bytes()).To(Equal(target.Data["jwks.json"]))
+			})
+
+			By("rotating an RSA key with another jwks_uri into the target", func() {
+				key, err := rsa.GenerateKey(rand.Reader, 2048)
+				Expect(err).NotTo(HaveOccurred())
+				crt, pemKey := generateCertForKey("rsa-cert", key)
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Annotations["rotator.gw.ei.telekom.de/oidc-jwks-uri"] = "https://keys.example.com/jwks"
+				source.Annotations["rotator.gw.ei.telekom.de/oidc-response-types"] = "code, id_token"
+				source.Data["tls.crt"] = crt
+				source.Data["tls.key"] = pemKey
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("listing the algorithms of all slots", func() {
+				metadata := `{"issuer":"https://issuer.example.com","jwks_uri":"https://keys.example.com/jwks",` +
+					`"response_types_supported":["code","id_token"],"subject_types_supported":["public"],` +
+					`"id_token_signing_alg_values_supported":["ES256","RS256"]}`
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["openid-configuration"]).To(MatchJSON(metadata))
+					g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cm), cm)).To(Succeed())
+					g.Expect(cm.Data["openid-configuration"]).To(MatchJSON(metadata))
+				}, timeout, interval).Should(Succeed(), "controller did not update the metadata within timeout")
+			})
+		})
+
+		It("keeps rotating when the issuer is invalid", func() {
+			By("rotating a new certificate with an http issuer into the target", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Annotations["rotator.gw.ei.telekom.de/oidc-issuer"] = "http://issuer.example.com"
+				source.Data["tls.crt"] = testCert("renewed")
+				source.Data["tls.key"] = testKey("renewed")
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("rotating the target and removing the metadata", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("renewed")))
+					g.Expect(target.Data).NotTo(HaveKey("openid-configuration"))
+					g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cm), cm)).To(Succeed())
+					g.Expect(cm.Data).NotTo(HaveKey("openid-configuration"))
+				}, timeout, interval).Should(Succeed(), "controller did not rotate the target secret within timeout")
+			})
+
+			By("emitting a warning event", func() {
+				Eventually(func(g Gomega) {
+					events := &eventsv1.EventList{}
+					g.Expect(k8sClient.List(ctx, events, client.InNamespace(namespace))).To(Succeed())
+					g.Expect(events.Items).To(ContainElement(SatisfyAll(
+						HaveField("Reason", "OIDCMetadataFailed"),
+						HaveField("Type", corev1.EventTypeWarning),
+						HaveField("Regarding.Name", "source"),
+					)))
+				}, timeout, interval).Should(Succeed(), "controller did not emit a warning event within timeout")
+			})
+		})
+	})
+
+	When("an Opaque source secret with custom data keys is created", func() {
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/certificate-data-key":    "public.pem",
+						"rotator.gw.ei.telekom.de/private-key-data-key":    "private.pem",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeOpaque,
+				Data: map[string][]byte{
+					"public.pem":  testCert("cert"),
+					"private.pem": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+
+		It("rotates the certificate and key of the named data keys", func() {
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+				g.Expect(target.Type).To(Equal(corev1.SecretTypeTLS))
+				g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert")))
+				g.Expect(target.Data["next-tls.key"]).To(Equal(testKey("cert")))
+				g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+			}, timeout, interval).Should(Succeed(), "controller did not create the target secret within timeout")
+		})
+	})
+
+	When("a source secret holding a JWK is created", func() {
+		BeforeEach(func() {
+			block, _ := pem.Decode(testKey("cert"))
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			Expect(err).NotTo(HaveOccurred())
+			ecKey := key.(*ecdsa.PrivateKey)
+			pub, err := ecKey.PublicKey.Bytes()
+			Expect(err).NotTo(HaveOccurred())
+			d, err := ecKey.Bytes()
+			Expect(err).NotTo(HaveOccurred())
+			certBlock, _ := pem.Decode(testCert("cert"))
+			jwk := fmt.Sprintf(`{"kty":"EC","crv":"P-256","x":%q,"y":%q,"d":%q,"x5c":[%q]}`,
+				base64.RawURLEncoding.EncodeToString(pub[1:33]), base64.RawURLEncoding.EncodeToString(pub[33:]),
+				base64.RawURLEncoding.EncodeToString(d), base64.StdEncoding.EncodeToString(certBlock.Bytes))
+
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/source-format":           "jwk",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeOpaque,
+				Data: map[string][]byte{
+					"jwk.json": []byte(jwk),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+
+		It("rotates the PEM encoded certificate and key of the JWK", func() {
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+				g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert")))
+				g.Expect(target.Data["next-tls.kid"]).To(Equal(generateUuid("cert")))
+				block, _ := pem.Decode(target.Data["next-tls.key"])
+				g.Expect(block).NotTo(BeNil())
+				g.Expect(block.Type).To(Equal("PRIVATE KEY"))
+				sourceBlock, _ := pem.Decode(testKey("cert"))
+				g.Expect(block.Bytes).To(Equal(sourceBlock.Bytes))
+			}, timeout, interval).Should(Succeed(), "controller did not create the target secret within timeout")
+		})
+	})
+
+	When("a source secret holding a PKCS#12 keystore is created", func() {
+		BeforeEach(func() {
+			block, _ := pem.Decode(testKey("cert"))
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			Expect(err).NotTo(HaveOccurred())
+			certBlock, _ := pem.Decode(testCert("cert"))
+			cert, err := x509.ParseCertificate(certBlock.Bytes)
+			Expect(err).NotTo(HaveOccurred())
+			entry := keystore.Entry{Alias: "cert", Key: key, Certs: []*x509.Certificate{cert}}
+			p12, err := keystore.EncodePKCS12([]keystore.Entry{entry}, "changeit", rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			password := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "keystore-password", Namespace: namespace},
+				Data:       map[string][]byte{"password": []byte("changeit")},
+			}
+			Expect(k8sClient.Create(ctx, password)).To(Succeed(), "creation of password secret failed")
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/source-format":           "pkcs12",
+						"rotator.gw.ei.telekom.de/password-secret":         "keystore-password",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeOpaque,
+				Data: map[string][]byte{
+					"keystore.p12": p12,
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+
+		It("rotates the PEM encoded certificate and key of the keystore", func() {
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+				g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert")))
+				block, _ := pem.Decode(target.Data["next-tls.key"])
+				g.Expect(block).NotTo(BeNil())
+				sourceBlock, _ := pem.Decode(testKey("cert"))
+				g.Expect(block.Bytes).To(Equal(sourceBlock.Bytes))
+			}, timeout, interval).Should(Succeed(), "controller did not create the target secret within timeout")
+		})
+	})
+
+	When("a source secret holding an unreadable keystore is created", func() {
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/source-format":           "pkcs12",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeOpaque,
+				Data: map[string][]byte{
+					"keystore.p12": []byte("not a keystore"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+
+		It("refuses the source with a warning event", func() {
+			Eventually(func(g Gomega) {
+				events := &eventsv1.EventList{}
+				g.Expect(k8sClient.List(ctx, events, client.InNamespace(namespace))).To(Succeed())
+				g.Expect(events.Items).To(ContainElement(SatisfyAll(
+					HaveField("Reason", "InvalidSource"),
+					HaveField("Type", corev1.EventTypeWarning),
+					HaveField("Regarding.Name", "source"),
+				)))
+			}, timeout, interval).Should(Succeed(), "controller did not emit a warning event within timeout")
+		})
+	})
+
+	When("a source secret with a key encoding is created", func() {
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/key-encoding":            "sec1",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+
+		It("normalizes the PKCS#8 key of the source into SEC 1", func() {
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+				block, _ := pem.Decode(target.Data["next-tls.key"])
+				g.Expect(block).NotTo(BeNil())
+				g.Expect(block.Type).To(Equal("EC PRIVATE KEY"))
+				key, err := x509.ParseECPrivateKey(block.Bytes)
+				g.Expect(err).NotTo(HaveOccurred())
+
+				sourceBlock, _ := pem.Decode(testKey("cert"))
+				sourceKey, err := x509.ParsePKCS8PrivateKey(sourceBlock.Bytes)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(key.Equal(sourceKey)).To(BeTrue(), "normalized key should be the key of the source")
+			}, timeout, interval).Should(Succeed(), "controller did not create the target secret within timeout")
+		})
+	})
+
+	When("a source secret with a key encoding its key cannot be converted to is created", func() {
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/key-encoding":            "pkcs1",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+
+		It("refuses the source", func() {
+			By("emitting a warning event", func() {
+				Eventually(func(g Gomega) {
+					events := &eventsv1.EventList{}
+					g.Expect(k8sClient.List(ctx, events, client.InNamespace(namespace))).To(Succeed())
+					g.Expect(events.Items).To(ContainElement(SatisfyAll(
+						HaveField("Reason", "KeyEncodingRejected"),
+						HaveField("Type", corev1.EventTypeWarning),
+						HaveField("Regarding.Name", "source"),
+						HaveField("Regarding.UID", source.UID),
+					)))
+				}, timeout, interval).Should(Succeed(), "controller did not emit a warning event within timeout")
+			})
+
+			By("not creating the target secret", func() {
+				Consistently(func(g Gomega) {
+					err := k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)
+					g.Expect(errors.IsNotFound(err)).To(BeTrue(), "target secret should not have been created")
+				}, time.Second*2, interval).Should(Succeed())
+			})
+		})
+	})
+
+	When("a source secret violates the key policy of its namespace", func() {
+		var policy *corev1.ConfigMap
+
+		BeforeEach(func() {
+			policy = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "rotator-key-policy",
+					Namespace: namespace,
+				},
+				Data: map[string]string{
+					"allowed-curves": "P-384,P-521",
+					"max-lifetime":   "720h",
+				},
+			}
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed(), "creation of key policy config map failed")
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, policy)).To(Succeed(), "deletion of key policy config map failed")
+			})
+
 			source = &corev1.Secret{
 				ObjectMeta: metav1.ObjectMeta{
 					Annotations: map[string]string{
@@ -339,21 +2669,491 @@ var _ = Describe("Secret Controller", Serial, func() {
 					Name:      "source",
 					Namespace: namespace,
 				},
+				Type: corev1.SecretTypeTLS,
 				Data: map[string][]byte{
-					"other-key": []byte("other-val"),
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
 				},
 			}
 			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
 		})
-		It("does nothing", func() {
+
+		It("refuses the source until the policy allows it", func() {
+			By("emitting a warning event for every violated rule", func() {
+				Eventually(func(g Gomega) {
+					events := &eventsv1.EventList{}
+					g.Expect(k8sClient.List(ctx, events, client.InNamespace(namespace))).To(Succeed())
+					g.Expect(events.Items).To(ContainElement(SatisfyAll(
+						HaveField("Reason", "KeyPolicyViolation"),
+						HaveField("Note", ContainSubstring("curve P-256 is not allowed")),
+						HaveField("Regarding.UID", source.UID),
+					)))
+					g.Expect(events.Items).To(ContainElement(SatisfyAll(
+						HaveField("Reason", "KeyPolicyViolation"),
+						HaveField("Note", ContainSubstring("at most 720h0m0s is allowed")),
+						HaveField("Regarding.UID", source.UID),
+					)))
+				}, timeout, interval).Should(Succeed(), "controller did not emit warning events within timeout")
+			})
+
+			By("counting the violations in the metrics", func() {
+				families, err := metrics.Registry.Gather()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(families).To(ContainElement(WithTransform(
+					func(family interface{ GetName() string }) string { return family.GetName() },
+					Equal("rotator_key_policy_violations_total"),
+				)))
+			})
+
+			By("counting the violations of an unchanged source only once", func() {
+				var count float64
+				Eventually(func(g Gomega) {
+					count = policyViolationCount(g, "source", "curve")
+					g.Expect(count).To(BeNumerically(">", 0))
+				}, timeout, interval).Should(Succeed(), "controller did not count the violation within timeout")
+
+				// trigger a reconciliation of the unchanged key material
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(source), source)).To(Succeed())
+				source.Annotations["some-new-annotation"] = "some-new-value"
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+
+				Consistently(func(g Gomega) {
+					g.Expect(policyViolationCount(g, "source", "curve")).To(Equal(count))
+				}, time.Second*2, interval).Should(Succeed(), "controller counted the same violation again")
+			})
+
+			By("not creating the target secret", func() {
+				Consistently(func(g Gomega) {
+					err := k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)
+					g.Expect(errors.IsNotFound(err)).To(BeTrue(), "target secret should not have been created")
+				}, time.Second*2, interval).Should(Succeed())
+			})
+
+			By("relaxing the key policy", func() {
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(policy), policy)).To(Succeed())
+				policy.Data = map[string]string{"allowed-curves": "P-256"}
+				Expect(k8sClient.Update(ctx, policy)).To(Succeed(), "update of key policy config map failed")
+			})
+
+			By("creating the target secret", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert")))
+				}, timeout, interval).Should(Succeed(), "controller did not create the target secret within timeout")
+			})
+		})
+	})
+
+	When("a source secret with an RSA key and an alg override is created", func() {
+		var rsaCert, rsaKey []byte
+
+		BeforeEach(func() {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).NotTo(HaveOccurred())
+			rsaCert, rsaKey = generateCertForKey("rsa-cert", key)
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/alg":                     "PS256",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": rsaCert,
+					"tls.key": rsaKey,
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
 			Eventually(func(g Gomega) {
-				secrets := &corev1.SecretList{}
-				err := k8sClient.List(ctx, secrets, client.InNamespace(namespace))
-				Expect(err).ToNot(HaveOccurred(), "failed to list secrets")
-				Expect(
-					secrets.Items,
-				).To(HaveLen(1), "controller should not have created a target secret")
-			}, timeout, interval).Should(Succeed())
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+			}, timeout, interval).Should(Succeed(), "controller did not create the target secret within timeout")
+		})
+
+		It("uses the alg of the annotation", func() {
+			Expect(target.Data["next-tls.alg"]).To(Equal([]byte("PS256")))
+		})
+
+		It("keeps the alg of each key when rotating to a key of another type", func() {
+			By("rotating an EC key into the source without override", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				delete(source.Annotations, "rotator.gw.ei.telekom.de/alg")
+				source.Data["tls.crt"] = testCert("cert")
+				source.Data["tls.key"] = testKey("cert")
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("detecting ES256 for the new key and keeping PS256 for the promoted key", func() {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.alg"]).To(Equal([]byte("ES256")))
+					g.Expect(target.Data["tls.crt"]).To(Equal(rsaCert))
+					g.Expect(target.Data["tls.alg"]).To(Equal([]byte("PS256")))
+				}, timeout, interval).Should(Succeed(), "controller did not rotate the target secret within timeout")
+			})
+		})
+	})
+
+	When("a source secret with an alg incompatible with its key is created", func() {
+		BeforeEach(func() {
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/alg":                     "RS256",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+
+		It("does not create the target secret", func() {
+			Consistently(func(g Gomega) {
+				err := k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)
+				g.Expect(errors.IsNotFound(err)).To(BeTrue())
+			}, time.Second*2, interval).Should(Succeed(), "controller created a target secret for an incompatible alg")
+		})
+	})
+
+	When("a source secret with rotation windows is created", func() {
+		// A Tuesday outside of the rotation window
+		outsideWindow := time.Date(2025, time.June, 3, 10, 0, 0, 0, time.UTC)
+		windowStart := time.Date(2025, time.June, 9, 2, 0, 0, 0, time.UTC)
+
+		BeforeEach(func() {
+			testClock.SetTime(outsideWindow)
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/source":                  "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+						"rotator.gw.ei.telekom.de/rotation-windows":        "Mon 02:00-04:00",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+			}, timeout, interval).Should(Succeed(), "controller did not create the target secret within timeout")
+		})
+
+		AfterEach(func() {
+			testClock.Reset()
+		})
+
+		It("stages source changes until the next window starts", func() {
+			By("changing the source outside of the window", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Data["tls.crt"] = testCert("cert-rotation-1")
+				source.Data["tls.key"] = testKey("cert-rotation-1")
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("staging the change", func() {
+				Eventually(func(g Gomega) {
+					events := &eventsv1.EventList{}
+					g.Expect(k8sClient.List(ctx, events, client.InNamespace(namespace))).To(Succeed())
+					g.Expect(events.Items).To(ContainElement(SatisfyAll(
+						HaveField("Reason", "RotationStaged"),
+						HaveField("Regarding.Name", "target"),
+					)))
+				}, timeout, interval).Should(Succeed(), "controller did not emit an event within timeout")
+				Consistently(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert")))
+					g.Expect(target.Data["tls.crt"]).To(BeEmpty())
+				}, time.Second*2, interval).Should(Succeed(), "the target secret should not have been rotated")
+			})
+
+			By("rotating once the window has started", func() {
+				testClock.SetTime(windowStart)
+				// trigger a reconciliation instead of waiting for the requeue
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Annotations["some-new-annotation"] = "some-new-value"
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert-rotation-1")))
+					g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+				}, timeout, interval).Should(Succeed(), "controller did not rotate the target secret within timeout")
+			})
+		})
+
+		It("stages rotate requests until the next window starts", func() {
+			By("requesting a rotation outside of the window", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Annotations["rotator.gw.ei.telekom.de/rotate-request"] = "1"
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("staging the request", func() {
+				Eventually(func(g Gomega) {
+					events := &eventsv1.EventList{}
+					g.Expect(k8sClient.List(ctx, events, client.InNamespace(namespace))).To(Succeed())
+					g.Expect(events.Items).To(ContainElement(SatisfyAll(
+						HaveField("Reason", "RotationStaged"),
+						HaveField("Regarding.UID", target.UID),
+					)))
+				}, timeout, interval).Should(Succeed(), "controller did not emit an event within timeout")
+				Consistently(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert")))
+					g.Expect(target.Data["tls.crt"]).To(BeEmpty())
+					g.Expect(target.Annotations).NotTo(HaveKey("rotator.gw.ei.telekom.de/last-rotate-request"))
+				}, time.Second*2, interval).Should(Succeed(), "the target secret should not have been rotated")
+			})
+
+			By("promoting next-tls.* once the window has started", func() {
+				testClock.SetTime(windowStart)
+				// trigger a reconciliation instead of waiting for the requeue
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				source.Annotations["some-new-annotation"] = "some-new-value"
+				Expect(k8sClient.Update(ctx, source)).To(Succeed(), "update of source secret by test runner failed")
+
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+						To(Succeed())
+					g.Expect(target.Data["tls.crt"]).To(Equal(testCert("cert")))
+					g.Expect(target.Annotations).
+						To(HaveKeyWithValue("rotator.gw.ei.telekom.de/last-rotate-request", "1"))
+				}, timeout, interval).Should(Succeed(), "controller did not rotate the target secret within timeout")
+			})
+		})
+	})
+
+	Context("dry-run mode", func() {
+		var reconciler controller.SecretReconciler
+		BeforeEach(func() {
+			reconciler = controller.SecretReconciler{
+				Client:               k8sClient,
+				Scheme:               k8sClient.Scheme(),
+				Recorder:             events.NewFakeRecorder(10),
+				SourceAnnotation:     "rotator.gw.ei.telekom.de/dry-run-source",
+				TargetNameAnnotation: "rotator.gw.ei.telekom.de/destination-secret-name",
+				Finalizer:            "rotator.gw.ei.telekom.de/finalizer",
+				Clock:                clock.RealClock{},
+				DryRun:               true,
+			}
+
+			// the source is marked with the source annotation of the dry-run reconciler, so that it is not
+			// reconciled by the controller of the test suite
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/dry-run-source":          "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+
+		It("does not create or update any secrets", func() {
+			val, err := reconciler.Reconcile(ctx, ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: "source", Namespace: namespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(val).To(BeEquivalentTo(ctrl.Result{}))
+
+			By("not creating the target secret", func() {
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)
+				Expect(errors.IsNotFound(err)).To(BeTrue(), "target secret should not have been created")
+			})
+
+			By("not adding the finalizer to the source secret", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "source", Namespace: namespace}, source)).
+					To(Succeed())
+				Expect(source.Finalizers).To(BeEmpty())
+			})
+		})
+
+		It("reports the slots of the target that would be created", func() {
+			changes := plannedSlotChanges(ctx, &reconciler, client.ObjectKeyFromObject(source))
+			Expect(changes).To(ConsistOf(
+				map[string]any{"slot": "next-tls", "from": "", "to": string(generateUuid("cert"))},
+			))
+		})
+
+		It("reports the slots of the target that would change in a rotation", func() {
+			By("creating the target secret outside of dry-run mode", func() {
+				reconciler.DryRun = false
+				_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(source)})
+				Expect(err).NotTo(HaveOccurred())
+				reconciler.DryRun = true
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+			})
+
+			By("changing the source", func() {
+				// the controller of the test suite releases the target of the source concurrently
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(source), source)).To(Succeed())
+					source.Data["tls.crt"] = testCert("cert-rotation-1")
+					source.Data["tls.key"] = testKey("cert-rotation-1")
+					g.Expect(k8sClient.Update(ctx, source)).To(Succeed())
+				}, timeout, interval).Should(Succeed(), "update of source secret by test runner failed")
+			})
+
+			By("reporting the promotion and the new next-tls.*", func() {
+				changes := plannedSlotChanges(ctx, &reconciler, client.ObjectKeyFromObject(source))
+				Expect(changes).To(ConsistOf(
+					map[string]any{"slot": "tls", "from": "", "to": string(generateUuid("cert"))},
+					map[string]any{
+						"slot": "next-tls",
+						"from": string(generateUuid("cert")),
+						"to":   string(generateUuid("cert-rotation-1")),
+					},
+				))
+			})
+
+			By("not rotating the target secret", func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+					To(Succeed())
+				Expect(target.Data["next-tls.crt"]).To(Equal(testCert("cert")))
+				Expect(target.Data["tls.crt"]).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("a reconciler without clock", func() {
+		var reconciler controller.SecretReconciler
+		BeforeEach(func() {
+			reconciler = controller.SecretReconciler{
+				Client:               k8sClient,
+				Scheme:               k8sClient.Scheme(),
+				Recorder:             events.NewFakeRecorder(10),
+				SourceAnnotation:     "rotator.gw.ei.telekom.de/standalone-source",
+				TargetNameAnnotation: "rotator.gw.ei.telekom.de/destination-secret-name",
+				Finalizer:            "rotator.gw.ei.telekom.de/finalizer",
+			}
+
+			// the source is marked with the source annotation of this reconciler, so that it is not reconciled by
+			// the controller of the test suite
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/standalone-source":       "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+
+		It("uses the real clock", func() {
+			before := time.Now().Truncate(time.Second)
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: "source", Namespace: namespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)).
+				To(Succeed())
+			publishedAt, err := time.Parse(time.RFC3339,
+				target.Annotations["rotator.gw.ei.telekom.de/next-tls-published-at"])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(publishedAt).To(BeTemporally(">=", before))
+		})
+	})
+
+	Context("a key policy config map that loosens the policy of the operator", func() {
+		var reconciler controller.SecretReconciler
+		BeforeEach(func() {
+			reconciler = controller.SecretReconciler{
+				Client:               k8sClient,
+				Scheme:               k8sClient.Scheme(),
+				Recorder:             events.NewFakeRecorder(10),
+				SourceAnnotation:     "rotator.gw.ei.telekom.de/standalone-source",
+				TargetNameAnnotation: "rotator.gw.ei.telekom.de/destination-secret-name",
+				Finalizer:            "rotator.gw.ei.telekom.de/finalizer",
+				KeyPolicy:            controller.KeyPolicy{AllowedCurves: []string{"P-384"}},
+			}
+
+			policy := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "rotator-key-policy",
+					Namespace: namespace,
+				},
+				Data: map[string]string{"allowed-curves": "P-256,P-384"},
+			}
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed(), "creation of key policy config map failed")
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, policy)).To(Succeed(), "deletion of key policy config map failed")
+			})
+
+			// the source is marked with the source annotation of this reconciler, so that it is not reconciled by
+			// the controller of the test suite
+			source = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"rotator.gw.ei.telekom.de/standalone-source":       "true",
+						"rotator.gw.ei.telekom.de/destination-secret-name": "target",
+					},
+					Name:      "source",
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					"tls.crt": testCert("cert"),
+					"tls.key": testKey("cert"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, source)).To(Succeed(), "creation of source secret failed")
+		})
+
+		It("rejects the override and doesn't rotate the source", func() {
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: "source", Namespace: namespace},
+			})
+			Expect(err).To(MatchError(ContainSubstring("allowed-curves must be a subset of P-384")))
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: "target", Namespace: namespace}, target)
+			Expect(errors.IsNotFound(err)).To(BeTrue(), "target secret should not have been created")
 		})
 	})
 
@@ -0,0 +1,185 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// retainedKeysAnnotation can be set on a source secret to configure how many previous keys are kept
+	// in the target secret.
+	retainedKeysAnnotation = "rotator.gw.ei.telekom.de/retained-keys"
+	// defaultRetainedKeys is the number of previous keys kept if the annotation is not set.
+	defaultRetainedKeys = 1
+	// maxRetainedKeys limits the number of previous keys to keep the target secret reasonably small.
+	maxRetainedKeys = 16
+
+	nextSlot   = "next-tls"
+	activeSlot = "tls"
+	prevSlot   = "prev-tls"
+)
+
+// slot holds the data of a single key slot in the target secret, e.g. the tls.* fields.
+type slot struct {
+	crt []byte
+	key []byte
+	kid []byte
+	alg []byte
+	// leaf holds the PEM encoded leaf certificate of crt and chain the PEM encoded certificates following it, x5c
+	// all certificates as JSON array of base64 encoded DER certificates.
+	leaf  []byte
+	chain []byte
+	x5c   []byte
+	// pub holds the PEM encoded SubjectPublicKeyInfo of the certificate in crt.
+	pub []byte
+}
+
+// newIncomingSlot returns the slot to rotate into the target from the certificate and key read from the source,
+// including its kid derived with the configured strategy, its alg, its leaf, chain and x5c, and its public key.
+func newIncomingSlot(source *corev1.Secret, material keyMaterial, opts rotationOptions) (slot, error) {
+	kid, err := deriveKid(source, material.crt, opts.kidStrategy)
+	if err != nil {
+		return slot{}, err
+	}
+	alg, err := deriveAlg(source, material.crt)
+	if err != nil {
+		return slot{}, err
+	}
+	leaf, chain, x5c, err := splitChain(material.crt)
+	if err != nil {
+		return slot{}, err
+	}
+	pub, err := publicKeyPEM(material.crt)
+	if err != nil {
+		return slot{}, err
+	}
+	return slot{
+		crt:   material.crt,
+		key:   material.key,
+		kid:   []byte(kid),
+		alg:   []byte(alg),
+		leaf:  leaf,
+		chain: chain,
+		x5c:   x5c,
+		pub:   pub,
+	}, nil
+}
+
+// readSlot reads the slot with the given name from the secret data.
+func readSlot(data map[string][]byte, name string) slot {
+	return slot{
+		crt:   data[name+".crt"],
+		key:   data[name+".key"],
+		kid:   data[name+".kid"],
+		alg:   data[name+".alg"],
+		leaf:  data[name+".leaf"],
+		chain: data[name+".chain"],
+		x5c:   data[name+".x5c"],
+		pub:   data[name+".pub"],
+	}
+}
+
+// writeSlot writes the given slot under the given name into the secret data.
+// Missing values are written as empty values, so that all fields of a slot are always present.
+func writeSlot(data map[string][]byte, name string, s slot) {
+	data[name+".crt"] = nonNil(s.crt)
+	data[name+".key"] = nonNil(s.key)
+	data[name+".kid"] = nonNil(s.kid)
+	data[name+".alg"] = nonNil(s.alg)
+	data[name+".leaf"] = nonNil(s.leaf)
+	data[name+".chain"] = nonNil(s.chain)
+	data[name+".x5c"] = nonNil(s.x5c)
+	data[name+".pub"] = nonNil(s.pub)
+}
+
+// prevSlotName returns the name of the i-th previous slot (starting at 1) if more than one previous key is retained.
+func prevSlotName(i int) string {
+	return fmt.Sprintf("prev-%d-tls", i)
+}
+
+// slotNames returns the names of all slots of a target retaining the given number of previous keys,
+// ordered from the newest (next-tls) to the oldest slot.
+// If only a single previous key is retained, the slot is called prev-tls. Otherwise, the previous slots are
+// numbered prev-1-tls to prev-N-tls.
+func slotNames(retainedKeys int) []string {
+	names := []string{nextSlot, activeSlot}
+	if retainedKeys == 1 {
+		return append(names, prevSlot)
+	}
+	for i := 1; i <= retainedKeys; i++ {
+		names = append(names, prevSlotName(i))
+	}
+	return names
+}
+
+// existingSlotNames returns the names of the slots currently present in the secret data,
+// ordered from the newest (next-tls) to the oldest slot.
+func existingSlotNames(data map[string][]byte) []string {
+	names := []string{nextSlot, activeSlot}
+	if _, ok := data[prevSlot+".kid"]; ok {
+		names = append(names, prevSlot)
+	}
+	for i := 1; ; i++ {
+		if _, ok := data[prevSlotName(i)+".kid"]; !ok {
+			break
+		}
+		names = append(names, prevSlotName(i))
+	}
+	return names
+}
+
+// slotData returns a copy of the fields of all slots in the secret data, without the outputs derived from them,
+// e.g. jwks.json.
+func slotData(data map[string][]byte) map[string][]byte {
+	slots := map[string][]byte{}
+	for _, name := range existingSlotNames(data) {
+		writeSlot(slots, name, readSlot(data, name))
+	}
+	return slots
+}
+
+// shiftedSlots returns a copy of the slots in the secret data, with every slot moved by the given offset towards
+// the older slots and laid out for the given number of retained keys. Slots that do not fit are dropped, slots that
+// don't have a predecessor are left empty.
+func shiftedSlots(data map[string][]byte, retainedKeys int, offset int) map[string][]byte {
+	current := existingSlotNames(data)
+	shifted := map[string][]byte{}
+	for i, name := range slotNames(retainedKeys) {
+		var s slot
+		if j := i - offset; j >= 0 && j < len(current) {
+			s = readSlot(data, current[j])
+		}
+		writeSlot(shifted, name, s)
+	}
+	return shifted
+}
+
+// retainedKeys returns the number of previous keys to keep in the target, as configured on the source.
+func retainedKeys(source *corev1.Secret) (int, error) {
+	val, ok := source.Annotations[retainedKeysAnnotation]
+	if !ok {
+		return defaultRetainedKeys, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", retainedKeysAnnotation, err)
+	}
+	if n < 1 || n > maxRetainedKeys {
+		return 0, fmt.Errorf("%s must be between 1 and %d, got %d", retainedKeysAnnotation, maxRetainedKeys, n)
+	}
+	return n, nil
+}
+
+// nonNil returns an empty slice instead of nil, so that empty fields are kept in the secret.
+func nonNil(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
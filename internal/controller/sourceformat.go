@@ -0,0 +1,226 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+
+	"gw.ei.telekom.de/rotator/internal/keystore"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// sourceFormatAnnotation can be set on a source secret to select the format its key material is read in.
+	sourceFormatAnnotation = "rotator.gw.ei.telekom.de/source-format"
+	// certificateDataKeyAnnotation can be set on a source secret in the pem format to name the data key holding the
+	// PEM encoded certificate chain.
+	certificateDataKeyAnnotation = "rotator.gw.ei.telekom.de/certificate-data-key"
+	// privateKeyDataKeyAnnotation can be set on a source secret in the pem format to name the data key holding the
+	// PEM encoded private key.
+	privateKeyDataKeyAnnotation = "rotator.gw.ei.telekom.de/private-key-data-key"
+	// dataKeyAnnotation can be set on a source secret in the jwk or pkcs12 format to name the data key holding the
+	// JWK or keystore.
+	dataKeyAnnotation = "rotator.gw.ei.telekom.de/data-key"
+	// passwordSecretAnnotation names the secret in the namespace of a source in the pkcs12 format that holds the
+	// password of the keystore.
+	passwordSecretAnnotation = "rotator.gw.ei.telekom.de/password-secret"
+	// passwordKeyAnnotation names the data key of the password secret holding the password.
+	passwordKeyAnnotation = "rotator.gw.ei.telekom.de/password-key"
+
+	// passwordSecretField indexes the sources by the names of the password secrets they read passwords from.
+	passwordSecretField = "passwordSecretName"
+
+	defaultJWKDataKey    = "jwk.json"
+	defaultPKCS12DataKey = "keystore.p12"
+	defaultPasswordKey   = "password"
+)
+
+// sourceFormat selects the format the key material of a source is read in.
+type sourceFormat string
+
+const (
+	// sourceFormatPEM reads a PEM encoded certificate chain and private key from two data keys. It is the default
+	// and reads tls.crt and tls.key, like kubernetes.io/tls secrets hold them.
+	sourceFormatPEM sourceFormat = "pem"
+	// sourceFormatJWK reads a single private JWK with its certificate chain in x5c.
+	sourceFormatJWK sourceFormat = "jwk"
+	// sourceFormatPKCS12 reads a PKCS#12 keystore holding a single private key and its certificate chain, encrypted
+	// with PBES2 or 3DES. Legacy keystores encrypted with RC2 or RC4 are not supported.
+	sourceFormatPKCS12 sourceFormat = "pkcs12"
+)
+
+// errMissingSourceData is returned if a source lacks the data its format reads the key material from.
+var errMissingSourceData = errors.New("source secret does not contain the key material")
+
+// keyMaterial holds the PEM encoded certificate chain and private key read from a source.
+type keyMaterial struct {
+	crt []byte
+	key []byte
+}
+
+// dataKey returns the data key named by the given annotation of the source, or the default if it is not set.
+func dataKey(source *corev1.Secret, annotation string, defaultKey string) string {
+	if key := source.Annotations[annotation]; key != "" {
+		return key
+	}
+	return defaultKey
+}
+
+// sourceData returns the value of the given data key of the source. It fails if the value is empty.
+func sourceData(source *corev1.Secret, key string) ([]byte, error) {
+	data := source.Data[key]
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: %s is missing or empty", errMissingSourceData, key)
+	}
+	return data, nil
+}
+
+// readKeyMaterial reads the certificate chain and private key of the source in the format it selects.
+// Key material read from JWKs and keystores is PEM encoded, with the private key in PKCS#8.
+func (r *SecretReconciler) readKeyMaterial(ctx context.Context, source *corev1.Secret) (keyMaterial, error) {
+	switch format := sourceFormat(source.Annotations[sourceFormatAnnotation]); format {
+	case "", sourceFormatPEM:
+		crt, err := sourceData(source, dataKey(source, certificateDataKeyAnnotation, "tls.crt"))
+		if err != nil {
+			return keyMaterial{}, err
+		}
+		key, err := sourceData(source, dataKey(source, privateKeyDataKeyAnnotation, "tls.key"))
+		if err != nil {
+			return keyMaterial{}, err
+		}
+		return keyMaterial{crt: crt, key: key}, nil
+	case sourceFormatJWK:
+		data, err := sourceData(source, dataKey(source, dataKeyAnnotation, defaultJWKDataKey))
+		if err != nil {
+			return keyMaterial{}, err
+		}
+		key, certs, err := parseJWKPrivateKey(data)
+		if err != nil {
+			return keyMaterial{}, err
+		}
+		if len(certs) == 0 {
+			return keyMaterial{}, errors.New("JWK must hold its certificate chain in x5c")
+		}
+		return encodeKeyMaterial(key, certs)
+	case sourceFormatPKCS12:
+		data, err := sourceData(source, dataKey(source, dataKeyAnnotation, defaultPKCS12DataKey))
+		if err != nil {
+			return keyMaterial{}, err
+		}
+		password, err := r.keystorePassword(ctx, source)
+		if err != nil {
+			return keyMaterial{}, err
+		}
+		key, certs, err := keystore.DecodePKCS12(data, password)
+		if err != nil {
+			return keyMaterial{}, err
+		}
+		if len(certs) == 0 {
+			return keyMaterial{}, errors.New("PKCS#12 keystore must hold the certificate of its private key")
+		}
+		ders := make([][]byte, 0, len(certs))
+		for _, cert := range certs {
+			ders = append(ders, cert.Raw)
+		}
+		return encodeKeyMaterial(key, ders)
+	default:
+		return keyMaterial{}, fmt.Errorf("%s must be one of %s, %s or %s, got %q", sourceFormatAnnotation,
+			sourceFormatPEM, sourceFormatJWK, sourceFormatPKCS12, format)
+	}
+}
+
+// loadKeyMaterial reads the certificate chain and private key of the source and returns whether it succeeded.
+// A source whose key material cannot be decoded is reported with a warning event. Missing data is only logged.
+func (r *SecretReconciler) loadKeyMaterial(ctx context.Context, source *corev1.Secret) (keyMaterial, bool) {
+	material, err := r.readKeyMaterial(ctx, source)
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to read certificate and key from the source secret")
+		if !errors.Is(err, errMissingSourceData) {
+			r.Recorder.Eventf(source, nil, corev1.EventTypeWarning, "InvalidSource", "Validate",
+				"Refusing to rotate unreadable source: %s", err.Error())
+		}
+		return keyMaterial{}, false
+	}
+	return material, true
+}
+
+// keystorePassword returns the password of the keystore of the source from its password secret.
+// The password is empty if the source doesn't name a password secret.
+func (r *SecretReconciler) keystorePassword(ctx context.Context, source *corev1.Secret) (string, error) {
+	name := source.Annotations[passwordSecretAnnotation]
+	if name == "" {
+		return "", nil
+	}
+	return r.password(ctx, source.Namespace, name, dataKey(source, passwordKeyAnnotation, defaultPasswordKey))
+}
+
+// password returns the password in the given data key of the secret with the given name and namespace.
+func (r *SecretReconciler) password(ctx context.Context, namespace string, name string, key string) (string, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+		return "", fmt.Errorf("getting password secret %s: %w", name, err)
+	}
+	password, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("password secret %s does not contain %s", name, key)
+	}
+	return string(password), nil
+}
+
+// passwordSecretNames returns the names of the password secrets the given source reads the password of its keystore,
+// or of the keystores written into its target, from. The sources are indexed by them, so that a change of a secret
+// only lists the sources reading from it.
+func (r *SecretReconciler) passwordSecretNames(obj client.Object) []string {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok || !r.isSource(secret) {
+		return nil
+	}
+	var names []string
+	for _, annotation := range []string{passwordSecretAnnotation, keystorePasswordSecretAnnotation} {
+		if name := secret.Annotations[annotation]; name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// sourcesWithPasswordSecret returns a reconcile request for every source in the namespace of the given secret that
+// reads the password of its keystore, or of the keystores written into its target, from it.
+func (r *SecretReconciler) sourcesWithPasswordSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	secrets := &corev1.SecretList{}
+	err := r.List(ctx, secrets, client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{passwordSecretField: obj.GetName()})
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list the source secrets", "namespace", obj.GetNamespace())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(secrets.Items))
+	for i := range secrets.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&secrets.Items[i])})
+	}
+	return requests
+}
+
+// encodeKeyMaterial PEM encodes the given private key in PKCS#8 and the given DER encoded certificates.
+func encodeKeyMaterial(key any, certs [][]byte) (keyMaterial, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return keyMaterial{}, fmt.Errorf("encoding private key: %w", err)
+	}
+	var crt []byte
+	for _, cert := range certs {
+		crt = append(crt, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})...)
+	}
+	return keyMaterial{crt: crt, key: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})}, nil
+}
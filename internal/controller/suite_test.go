@@ -8,7 +8,9 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -35,8 +37,42 @@ var (
 	cfg       *rest.Config
 	k8sClient client.Client
 	namespace string = "default"
+	testClock        = &shiftedClock{}
 )
 
+// shiftedClock follows the real time shifted by an adjustable offset, so that tests can move the reconciler to a
+// certain point in time while timeouts and requeues still work as usual.
+type shiftedClock struct {
+	mu     sync.Mutex
+	offset time.Duration
+}
+
+// Now returns the shifted current time.
+func (c *shiftedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Add(c.offset)
+}
+
+// Since returns the time elapsed since t according to the shifted current time.
+func (c *shiftedClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// SetTime shifts the clock, so that it currently returns the given time.
+func (c *shiftedClock) SetTime(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset = time.Until(t)
+}
+
+// Reset removes the shift of the clock.
+func (c *shiftedClock) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset = 0
+}
+
 // TestControllers is the entry point for all tests in controller_test.
 func TestControllers(t *testing.T) {
 	RegisterFailHandler(Fail)
@@ -80,12 +116,14 @@ var _ = BeforeSuite(func() {
 	})
 	Expect(err).ToNot(HaveOccurred())
 
+	// The event recorder is not set, so that the default event recorder of the manager is used
 	err = (&controller.SecretReconciler{
 		Client:               k8sManager.GetClient(),
 		Scheme:               k8sManager.GetScheme(),
 		SourceAnnotation:     "rotator.gw.ei.telekom.de/source",
 		TargetNameAnnotation: "rotator.gw.ei.telekom.de/destination-secret-name",
 		Finalizer:            "rotator.gw.ei.telekom.de/finalizer",
+		Clock:                testClock,
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
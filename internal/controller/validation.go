@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// invalidSourceAnnotation is set on a source secret whose certificate or key has been refused and carries the reason.
+// It is removed once the source is valid again.
+const invalidSourceAnnotation = "rotator.gw.ei.telekom.de/invalid-source"
+
+// parseCertificate parses the first PEM encoded certificate in the given data.
+func parseCertificate(crt []byte) (*x509.Certificate, error) {
+	rest := crt
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, errors.New("tls.crt does not contain a PEM encoded certificate")
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate in tls.crt: %w", err)
+		}
+		return cert, nil
+	}
+}
+
+// publicKeyPEM returns the public key of the first PEM encoded certificate in the given data as PEM encoded
+// SubjectPublicKeyInfo.
+func publicKeyPEM(crt []byte) ([]byte, error) {
+	cert, err := parseCertificate(crt)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("encoding public key of tls.crt: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// parsePrivateKey parses the PEM encoded private key in the given data. Keys in the PKCS#1, PKCS#8 and SEC 1
+// formats are supported.
+func parsePrivateKey(key []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("tls.key does not contain a PEM encoded private key")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("tls.key contains an unsupported PEM block of type %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key in tls.key: %w", err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("tls.key contains an unsupported private key of type %T", parsed)
+	}
+	return signer, nil
+}
+
+// validateKeyPair parses the PEM encoded certificate and private key and checks that the certificate has not
+// expired at the given time and that the key belongs to the public key of the certificate.
+func validateKeyPair(crt []byte, key []byte, now time.Time) error {
+	cert, err := parseCertificate(crt)
+	if err != nil {
+		return err
+	}
+	if !now.Before(cert.NotAfter) {
+		return fmt.Errorf("certificate in tls.crt expired at %s", formatTime(cert.NotAfter))
+	}
+	signer, err := parsePrivateKey(key)
+	if err != nil {
+		return err
+	}
+	public, ok := signer.Public().(interface{ Equal(x crypto.PublicKey) bool })
+	if !ok || !public.Equal(cert.PublicKey) {
+		return errors.New("private key in tls.key does not match the public key of the certificate in tls.crt")
+	}
+	return nil
+}
+
+// checkSource validates the certificate and key read from the source at the given time and returns whether they can
+// be rotated. An invalid source gets a warning event and the reason in its invalid-source annotation, which is removed
+// again once the source is valid. An error is only returned if the source cannot be updated.
+func (r *SecretReconciler) checkSource(
+	ctx context.Context,
+	source *corev1.Secret,
+	material keyMaterial,
+	now time.Time,
+) (bool, error) {
+	log := logf.FromContext(ctx)
+
+	reason := ""
+	if err := validateKeyPair(material.crt, material.key, now); err != nil {
+		reason = err.Error()
+		log.Error(err, "Refusing to rotate invalid source secret")
+		r.Recorder.Eventf(source, nil, corev1.EventTypeWarning, "InvalidSource", "Validate",
+			"Refusing to rotate invalid source: %s", reason)
+	}
+	if source.Annotations[invalidSourceAnnotation] == reason {
+		return reason == "", nil
+	}
+
+	if reason == "" {
+		delete(source.Annotations, invalidSourceAnnotation)
+	} else {
+		metav1.SetMetaDataAnnotation(&source.ObjectMeta, invalidSourceAnnotation, reason)
+	}
+	if err := r.update(ctx, source); err != nil {
+		log.Error(err, "Failed to update the invalid-source annotation of the source secret")
+		return false, err
+	}
+	return reason == "", nil
+}
@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// expiryWarningAnnotation can be set on a source secret to configure how long before its expiry the active key
+	// is flagged as expiring.
+	expiryWarningAnnotation = "rotator.gw.ei.telekom.de/expiry-warning"
+	// defaultExpiryWarning is the period before the expiry of the active key in which it is flagged as expiring,
+	// if the annotation is not set.
+	defaultExpiryWarning = 7 * 24 * time.Hour
+)
+
+// expiryWarning returns the period before the expiry of the active key in which it is flagged as expiring,
+// as configured on the source.
+func expiryWarning(source *corev1.Secret) (time.Duration, error) {
+	val, ok := source.Annotations[expiryWarningAnnotation]
+	if !ok {
+		return defaultExpiryWarning, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", expiryWarningAnnotation, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s must not be negative, got %s", expiryWarningAnnotation, val)
+	}
+	return d, nil
+}
+
+// holdBackPromotion checks whether the certificate in next-tls.* can be promoted to tls.* at the given time.
+// It returns how long the promotion has to be delayed until the certificate becomes valid. An expired certificate
+// is never promoted, it is removed from next-tls.* with a warning event instead.
+// Empty slots and slots without a parsable certificate are not held back. It does not update the secret in the cluster.
+func (r *SecretReconciler) holdBackPromotion(
+	ctx context.Context,
+	source *corev1.Secret,
+	target *corev1.Secret,
+	now time.Time,
+) time.Duration {
+	log := logf.FromContext(ctx)
+
+	cert, err := parseCertificate(target.Data["next-tls.crt"])
+	if err != nil {
+		return 0
+	}
+	switch {
+	case now.Before(cert.NotBefore):
+		wait := cert.NotBefore.Sub(now)
+		log.Info("Holding back promotion, the certificate in next-tls is not valid yet", "requeueAfter", wait)
+		return wait
+	case !now.Before(cert.NotAfter):
+		kid := string(target.Data["next-tls.kid"])
+		log.Info("Refusing to promote the expired certificate in next-tls, removing it", "kid", kid)
+		r.Recorder.Eventf(target, source, corev1.EventTypeWarning, "ExpiredKeyNotPromoted", "Rotate",
+			"Refusing to promote kid %s, its certificate expired at %s", kid, formatTime(cert.NotAfter))
+		writeSlot(target.Data, nextSlot, slot{})
+	}
+	return 0
+}
+
+// checkActiveExpiry flags the key in tls.* with a warning event if it expires within the given period or has
+// expired. It returns a result that requeues the reconciliation when the key has to be flagged next.
+func (r *SecretReconciler) checkActiveExpiry(
+	ctx context.Context,
+	source *corev1.Secret,
+	target *corev1.Secret,
+	warning time.Duration,
+	now time.Time,
+) ctrl.Result {
+	log := logf.FromContext(ctx)
+
+	cert, err := parseCertificate(target.Data["tls.crt"])
+	if err != nil {
+		return ctrl.Result{}
+	}
+	kid := string(target.Data["tls.kid"])
+	warnAt := cert.NotAfter.Add(-warning)
+	switch {
+	case now.Before(warnAt):
+		return ctrl.Result{RequeueAfter: warnAt.Sub(now)}
+	case now.Before(cert.NotAfter):
+		log.Info("The active key is about to expire", "kid", kid, "notAfter", cert.NotAfter)
+		r.Recorder.Eventf(target, source, corev1.EventTypeWarning, "ActiveKeyExpiring", "Rotate",
+			"The active kid %s expires at %s", kid, formatTime(cert.NotAfter))
+		return ctrl.Result{RequeueAfter: cert.NotAfter.Sub(now)}
+	default:
+		log.Info("The active key has expired", "kid", kid, "notAfter", cert.NotAfter)
+		r.Recorder.Eventf(target, source, corev1.EventTypeWarning, "ActiveKeyExpired", "Rotate",
+			"The active kid %s expired at %s", kid, formatTime(cert.NotAfter))
+		return ctrl.Result{}
+	}
+}
+
+// earliestRequeue returns the result that requeues the reconciliation first. Results without a requeue are ignored.
+func earliestRequeue(a ctrl.Result, b ctrl.Result) ctrl.Result {
+	if a.RequeueAfter == 0 || (b.RequeueAfter > 0 && b.RequeueAfter < a.RequeueAfter) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,155 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package keystore
+
+import (
+	"bytes"
+	"crypto/sha1" //nolint:gosec // SHA-1 is mandated by the JKS format
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	// jksMagic and jksVersion identify a JKS keystore.
+	jksMagic   = 0xfeedfeed
+	jksVersion = 2
+	// jksPrivateKeyTag marks a private key entry.
+	jksPrivateKeyTag = 1
+	// jksSaltSize is the size of the salt of the key protector, equal to the size of a SHA-1 digest.
+	jksSaltSize = sha1.Size
+	// jksIntegrityLabel is appended to the password to compute the integrity digest of a JKS keystore.
+	jksIntegrityLabel = "Mighty Aphrodite"
+)
+
+// oidJKSKeyProtector is the object identifier of the proprietary key protection algorithm of JKS keystores.
+//
+//nolint:gochecknoglobals // read-only object identifier
+var oidJKSKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+// EncodeJKS encodes the given entries into a JKS keystore protected by the given password. Like the JDK, which looks
+// up JKS entries by their lowercase alias, the aliases are stored in lowercase and have to be unique in lowercase.
+// As creation date of an entry the NotBefore of its leaf certificate is stored, so that the output only depends on
+// the entries, the password and the random source the salts are read from.
+func EncodeJKS(entries []Entry, password string, random io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	write := func(v any) { _ = binary.Write(&buf, binary.BigEndian, v) }
+	write(uint32(jksMagic))
+	write(uint32(jksVersion))
+	write(uint32(len(entries))) //nolint:gosec // the number of entries is small
+
+	aliases := map[string]bool{}
+	for _, entry := range entries {
+		alias := strings.ToLower(entry.Alias)
+		if aliases[alias] {
+			return nil, fmt.Errorf("duplicate alias %q", alias)
+		}
+		aliases[alias] = true
+		if len(entry.Certs) == 0 {
+			return nil, fmt.Errorf("entry %q has no certificate", alias)
+		}
+
+		protected, err := protectJKSKey(entry, password, random)
+		if err != nil {
+			return nil, err
+		}
+		write(uint32(jksPrivateKeyTag))
+		if err = writeUTF(&buf, alias); err != nil {
+			return nil, err
+		}
+		write(entry.Certs[0].NotBefore.UnixMilli())
+		write(uint32(len(protected))) //nolint:gosec // the size of a protected key is small
+		buf.Write(protected)
+		write(uint32(len(entry.Certs))) //nolint:gosec // the length of a chain is small
+		for _, cert := range entry.Certs {
+			if err = writeUTF(&buf, "X.509"); err != nil {
+				return nil, err
+			}
+			write(uint32(len(cert.Raw))) //nolint:gosec // the size of a certificate is small
+			buf.Write(cert.Raw)
+		}
+	}
+
+	digest := sha1.New() //nolint:gosec // mandated by the JKS format
+	digest.Write(jksPassword(password))
+	digest.Write([]byte(jksIntegrityLabel))
+	digest.Write(buf.Bytes())
+	return digest.Sum(buf.Bytes()), nil
+}
+
+// protectJKSKey returns the EncryptedPrivateKeyInfo of the key of the given entry, protected with the proprietary
+// algorithm of the JDK: the PKCS#8 encoded key is XORed with a key stream of chained SHA-1 digests of the password
+// and a random salt, followed by the SHA-1 digest of the password and the key to check its integrity.
+func protectJKSKey(entry Entry, password string, random io.Reader) ([]byte, error) {
+	key, err := x509.MarshalPKCS8PrivateKey(entry.Key)
+	if err != nil {
+		return nil, fmt.Errorf("encoding private key of %q: %w", entry.Alias, err)
+	}
+	salt := make([]byte, jksSaltSize)
+	if _, err = io.ReadFull(random, salt); err != nil {
+		return nil, fmt.Errorf("generating salt: %w", err)
+	}
+
+	passwordBytes := jksPassword(password)
+	protected := append([]byte{}, salt...)
+	digest := salt
+	for i := 0; i < len(key); i += sha1.Size {
+		h := sha1.New() //nolint:gosec // mandated by the JKS format
+		h.Write(passwordBytes)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		for j := 0; j < sha1.Size && i+j < len(key); j++ {
+			protected = append(protected, key[i+j]^digest[j])
+		}
+	}
+	check := sha1.New() //nolint:gosec // mandated by the JKS format
+	check.Write(passwordBytes)
+	check.Write(key)
+	protected = check.Sum(protected)
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oidJKSKeyProtector,
+			Parameters: asn1.RawValue{Tag: asn1.TagNull},
+		},
+		EncryptedData: protected,
+	})
+}
+
+// jksPassword returns the password as the JDK feeds it into digests, i.e. as big-endian UTF-16 without terminator.
+func jksPassword(password string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(password)) {
+		b = binary.BigEndian.AppendUint16(b, c)
+	}
+	return b
+}
+
+// writeUTF writes the string in the modified UTF-8 encoding of Java's DataOutput.writeUTF, prefixed with its length.
+func writeUTF(buf *bytes.Buffer, s string) error {
+	var encoded []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		switch {
+		case c >= 0x01 && c <= 0x7f:
+			encoded = append(encoded, byte(c))
+		case c <= 0x7ff:
+			encoded = append(encoded, byte(0xc0|c>>6), byte(0x80|c&0x3f))
+		default:
+			encoded = append(encoded, byte(0xe0|c>>12), byte(0x80|c>>6&0x3f), byte(0x80|c&0x3f))
+		}
+	}
+	if len(encoded) > math.MaxUint16 {
+		return fmt.Errorf("string of %d bytes is too long", len(encoded))
+	}
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(encoded)))
+	buf.Write(encoded)
+	return nil
+}
@@ -0,0 +1,255 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package keystore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec // SHA-1 is mandated by the JKS format
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// jksEntry is a decoded private key entry of a JKS keystore.
+type jksEntry struct {
+	Entry
+	Date time.Time
+}
+
+// decodeJKS decodes a JKS keystore the way the JDK does, verifying the integrity digest of the keystore and of each
+// protected key. Only the private key entries written by EncodeJKS are supported.
+func decodeJKS(data []byte, password string) ([]jksEntry, error) {
+	if len(data) < sha1.Size {
+		return nil, errors.New("keystore is too short")
+	}
+	body, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	hash := sha1.New() //nolint:gosec // mandated by the format
+	hash.Write(jksPassword(password))
+	hash.Write([]byte(jksIntegrityLabel))
+	hash.Write(body)
+	if !bytes.Equal(hash.Sum(nil), digest) {
+		return nil, errors.New("keystore was tampered with, or password was incorrect")
+	}
+
+	r := bytes.NewReader(body)
+	var header struct{ Magic, Version, Count uint32 }
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Magic != jksMagic || header.Version != jksVersion {
+		return nil, errors.New("invalid keystore format")
+	}
+
+	entries := make([]jksEntry, 0, header.Count)
+	for range header.Count {
+		entry, err := decodeJKSEntry(r, password)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if r.Len() > 0 {
+		return nil, errors.New("trailing data")
+	}
+	return entries, nil
+}
+
+// decodeJKSEntry decodes the next private key entry of a JKS keystore.
+func decodeJKSEntry(r *bytes.Reader, password string) (jksEntry, error) {
+	var entry jksEntry
+	var tag uint32
+	var date int64
+	if err := binary.Read(r, binary.BigEndian, &tag); err != nil {
+		return entry, err
+	}
+	if tag != jksPrivateKeyTag {
+		return entry, fmt.Errorf("unsupported entry tag %d", tag)
+	}
+	alias, err := readUTF(r)
+	if err != nil {
+		return entry, err
+	}
+	if err = binary.Read(r, binary.BigEndian, &date); err != nil {
+		return entry, err
+	}
+	protected, err := readBytes(r)
+	if err != nil {
+		return entry, err
+	}
+	key, err := unprotectJKSKey(protected, password)
+	if err != nil {
+		return entry, err
+	}
+
+	var count uint32
+	if err = binary.Read(r, binary.BigEndian, &count); err != nil {
+		return entry, err
+	}
+	certs := make([]*x509.Certificate, 0, count)
+	for range count {
+		certType, err := readUTF(r)
+		if err != nil {
+			return entry, err
+		}
+		if certType != "X.509" {
+			return entry, fmt.Errorf("unsupported certificate type %q", certType)
+		}
+		der, err := readBytes(r)
+		if err != nil {
+			return entry, err
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return entry, err
+		}
+		certs = append(certs, cert)
+	}
+	return jksEntry{Entry: Entry{Alias: alias, Key: key, Certs: certs}, Date: time.UnixMilli(date)}, nil
+}
+
+// unprotectJKSKey decrypts a key protected by the proprietary key protector of the JDK.
+func unprotectJKSKey(protected []byte, password string) (crypto.PrivateKey, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(protected, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algorithm.Algorithm.Equal(oidJKSKeyProtector) {
+		return nil, fmt.Errorf("unsupported key protector %s", info.Algorithm.Algorithm)
+	}
+	data := info.EncryptedData
+	if len(data) < jksSaltSize+sha1.Size {
+		return nil, errors.New("protected key is too short")
+	}
+	salt, encrypted, check := data[:jksSaltSize], data[jksSaltSize:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+
+	pw := jksPassword(password)
+	plain := make([]byte, len(encrypted))
+	digest := salt
+	for i := 0; i < len(encrypted); i += sha1.Size {
+		hash := sha1.New() //nolint:gosec // mandated by the format
+		hash.Write(pw)
+		hash.Write(digest)
+		digest = hash.Sum(nil)
+		for j := 0; j < sha1.Size && i+j < len(encrypted); j++ {
+			plain[i+j] = encrypted[i+j] ^ digest[j]
+		}
+	}
+	hash := sha1.New() //nolint:gosec // mandated by the format
+	hash.Write(pw)
+	hash.Write(plain)
+	if !bytes.Equal(hash.Sum(nil), check) {
+		return nil, errors.New("cannot recover key")
+	}
+	return x509.ParsePKCS8PrivateKey(plain)
+}
+
+// readUTF reads a string written by writeUTF. The modified UTF-8 of Java equals UTF-8 for the strings of the tests.
+func readUTF(r io.Reader) (string, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	s := make([]byte, length)
+	_, err := io.ReadFull(r, s)
+	return string(s), err
+}
+
+// readBytes reads a byte slice prefixed by its 32 bit length.
+func readBytes(r io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	b := make([]byte, length)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+var _ = ginkgo.Describe("JKS", func() {
+	ginkgo.It("decodes the entries of an encoded keystore", func() {
+		keyA, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		keyB, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		entries := []Entry{newTestEntry("Kid-A", keyA), newTestEntry("kid-b", keyB)}
+
+		encoded, err := EncodeJKS(entries, "pässword", rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		decoded, err := decodeJKS(encoded, "pässword")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decoded).To(HaveLen(2))
+		Expect(decoded[0].Alias).To(Equal("kid-a"))
+		Expect(decoded[1].Alias).To(Equal("kid-b"))
+		for i, entry := range entries {
+			Expect(entry.Key.(privateKey).Equal(decoded[i].Key)).To(BeTrue())
+			Expect(rawCerts(decoded[i].Certs)).To(Equal(rawCerts(entry.Certs)))
+			Expect(decoded[i].Date).To(BeTemporally("==", entry.Certs[0].NotBefore))
+		}
+	})
+
+	ginkgo.It("protects the keystore with the password", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		encoded, err := EncodeJKS([]Entry{newTestEntry("kid", key)}, testPassword, rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = decodeJKS(encoded, "wrong")
+		Expect(err).To(MatchError(ContainSubstring("password was incorrect")))
+	})
+
+	ginkgo.It("rejects aliases that are equal in lowercase", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = EncodeJKS([]Entry{newTestEntry("kid", key), newTestEntry("KID", key)}, testPassword, rand.Reader)
+		Expect(err).To(MatchError(ContainSubstring("duplicate alias")))
+	})
+
+	ginkgo.It("rejects entries without certificate", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = EncodeJKS([]Entry{{Alias: "kid", Key: key}}, testPassword, rand.Reader)
+		Expect(err).To(MatchError(ContainSubstring("has no certificate")))
+	})
+
+	ginkgo.It("writes keystores keytool can read", func() {
+		keytool, err := exec.LookPath("keytool")
+		if err != nil {
+			ginkgo.Skip("keytool is not installed")
+		}
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		encoded, err := EncodeJKS([]Entry{newTestEntry("Kid-A", key)}, testPassword, rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		file := filepath.Join(ginkgo.GinkgoT().TempDir(), "keystore.jks")
+		Expect(os.WriteFile(file, encoded, 0o600)).To(Succeed())
+
+		out, err := exec.Command(keytool, "-list", "-v", "-keystore", file, //nolint:gosec // test
+			"-storepass", testPassword, "-storetype", "JKS").CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+		Expect(string(out)).To(ContainSubstring("Alias name: kid-a"))
+		Expect(string(out)).To(ContainSubstring("Entry type: PrivateKeyEntry"))
+		Expect(string(out)).To(ContainSubstring("Certificate chain length: 2"))
+	})
+})
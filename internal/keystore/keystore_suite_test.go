@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package keystore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// TestKeystore is the entry point for all tests of the keystore package.
+func TestKeystore(t *testing.T) {
+	RegisterFailHandler(ginkgo.Fail)
+
+	ginkgo.RunSpecs(t, "Keystore Suite")
+}
+
+// privateKey is implemented by all private keys of the standard library.
+type privateKey interface {
+	Equal(x crypto.PrivateKey) bool
+}
+
+// newTestEntry returns an entry with the given alias and key, and a chain of a leaf certificate of the key and the
+// certificate of a freshly generated CA that issued it.
+func newTestEntry(alias string, key crypto.Signer) Entry {
+	notBefore := time.Now().Add(-time.Hour).Truncate(time.Second)
+	notAfter := notBefore.AddDate(1, 0, 0)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: alias + "-ca"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	Expect(err).NotTo(HaveOccurred())
+	ca, err := x509.ParseCertificate(caDer)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: alias},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	Expect(err).NotTo(HaveOccurred())
+	leaf, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+
+	return Entry{Alias: alias, Key: key, Certs: []*x509.Certificate{leaf, ca}}
+}
+
+// rawCerts returns the DER encoded certificates.
+func rawCerts(certs []*x509.Certificate) [][]byte {
+	raw := make([][]byte, 0, len(certs))
+	for _, cert := range certs {
+		raw = append(raw, cert.Raw)
+	}
+	return raw
+}
+
+// readTestdata returns the content of the given file in the testdata directory.
+func readTestdata(name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	Expect(err).NotTo(HaveOccurred())
+	return data
+}
+
+// readTestdataPEM returns the DER bytes of the first PEM block in the given file in the testdata directory.
+func readTestdataPEM(name string) []byte {
+	block, _ := pem.Decode(readTestdata(name))
+	Expect(block).NotTo(BeNil(), "%s does not contain a PEM block", name)
+	return block.Bytes
+}
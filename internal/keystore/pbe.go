@@ -0,0 +1,321 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package keystore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des" //nolint:gosec // 3DES is only supported to read legacy keystores
+	"crypto/pbkdf2"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	_ "crypto/sha1" // register SHA-1 for legacy keystores
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+const (
+	// Purposes of the key derivation function of RFC 7292, appendix B.
+	kdfEncryptionKey = 1
+	kdfIV            = 2
+	kdfMacKey        = 3
+
+	// pbkdf2Iterations is the number of PBKDF2 iterations used by written keystores.
+	pbkdf2Iterations = 10000
+	// maxIterations limits the iteration counts of read keystores, so that a crafted keystore can't stall the
+	// reconciliation. It is far above the counts used by common tools, e.g. 2048 by OpenSSL.
+	maxIterations = 1000000
+	// aes256KeySize is the key size of AES-256.
+	aes256KeySize = 32
+	// tripleDESKeySize is the key size of 3DES.
+	tripleDESKeySize = 24
+)
+
+// Object identifiers of the supported encryption, key derivation and hash algorithms.
+//
+//nolint:gochecknoglobals // read-only object identifiers
+var (
+	oidPBES2                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidPBEWithSHAAnd3KeyTDES = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidHMACWithSHA1          = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256        = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384        = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512        = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC             = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC             = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC             = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA1                  = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256                = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384                = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512                = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+// hashOf returns the hash of the given digest or HMAC algorithm.
+func hashOf(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA1), oid.Equal(oidHMACWithSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidSHA256), oid.Equal(oidHMACWithSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384), oid.Equal(oidHMACWithSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512), oid.Equal(oidHMACWithSHA512):
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported hash algorithm %s", oid)
+	}
+}
+
+// checkIterations returns an error if the iteration count of a read keystore is not positive or exceeds
+// maxIterations. It has to be called before any key derivation with the count.
+func checkIterations(iterations int) error {
+	if iterations < 1 || iterations > maxIterations {
+		return fmt.Errorf("unsupported iteration count %d, must be between 1 and %d", iterations, maxIterations)
+	}
+	return nil
+}
+
+// pkcs12KDF derives size bytes for the given purpose from the password, encoded as BMPString, as defined in
+// RFC 7292, appendix B.2.
+func pkcs12KDF(hash crypto.Hash, salt []byte, password []byte, iterations int, purpose byte, size int) []byte {
+	v := hash.New().BlockSize()
+
+	// D is a block filled with the purpose, I the salt and password each repeated to a multiple of the block size
+	d := bytes.Repeat([]byte{purpose}, v)
+	fill := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+		filled := make([]byte, v*((len(b)+v-1)/v))
+		for i := range filled {
+			filled[i] = b[i%len(b)]
+		}
+		return filled
+	}
+	i := append(fill(salt), fill(password)...)
+
+	one := big.NewInt(1)
+	var derived []byte
+	for len(derived) < size {
+		h := hash.New()
+		h.Write(d)
+		h.Write(i)
+		a := h.Sum(nil)
+		for range iterations - 1 {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(a[:0])
+		}
+		derived = append(derived, a...)
+
+		// Add B + 1 to every block of I, where B is A repeated to the block size
+		b := new(big.Int).SetBytes(fill(a)[:v])
+		b.Add(b, one)
+		for j := 0; j < len(i); j += v {
+			block := new(big.Int).SetBytes(i[j : j+v])
+			block.Add(block, b)
+			sum := block.Bytes()
+			if len(sum) > v {
+				sum = sum[len(sum)-v:]
+			}
+			clear(i[j : j+v])
+			copy(i[j+v-len(sum):j+v], sum)
+		}
+	}
+	return derived[:size]
+}
+
+// decrypt decrypts the given data with the password and the PBES2 or pbeWithSHAAnd3-KeyTripleDES-CBC algorithm.
+func decrypt(algorithm pkix.AlgorithmIdentifier, encrypted []byte, password string) ([]byte, error) {
+	var block cipher.Block
+	var iv []byte
+	var err error
+	switch {
+	case algorithm.Algorithm.Equal(oidPBES2):
+		block, iv, err = pbes2Cipher(algorithm.Parameters.FullBytes, password)
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd3KeyTDES):
+		block, iv, err = tripleDESCipher(algorithm.Parameters.FullBytes, password)
+	default:
+		return nil, fmt.Errorf("unsupported PKCS#12 encryption algorithm %s", algorithm.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(encrypted) == 0 || len(encrypted)%block.BlockSize() != 0 {
+		return nil, errors.New("decrypting PKCS#12: invalid ciphertext length")
+	}
+	decrypted := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, encrypted)
+
+	// Remove the PKCS#7 padding
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > block.BlockSize() ||
+		!bytes.Equal(decrypted[len(decrypted)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errors.New("decrypting PKCS#12: wrong password or corrupted keystore")
+	}
+	return decrypted[:len(decrypted)-padding], nil
+}
+
+// pbes2Cipher returns the AES cipher and IV of the given PBES2 parameters with a key derived by PBKDF2.
+func pbes2Cipher(params []byte, password string) (cipher.Block, []byte, error) {
+	var pbes2 pbes2Params
+	if _, err := asn1.Unmarshal(params, &pbes2); err != nil {
+		return nil, nil, fmt.Errorf("parsing PBES2 parameters: %w", err)
+	}
+	if !pbes2.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, nil, fmt.Errorf("unsupported PBES2 key derivation function %s", pbes2.KeyDerivationFunc.Algorithm)
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(pbes2.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, nil, fmt.Errorf("parsing PBKDF2 parameters: %w", err)
+	}
+	if err := checkIterations(kdf.IterationCount); err != nil {
+		return nil, nil, fmt.Errorf("parsing PBKDF2 parameters: %w", err)
+	}
+	prf := crypto.SHA1
+	if len(kdf.PRF.Algorithm) > 0 {
+		var err error
+		if prf, err = hashOf(kdf.PRF.Algorithm); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	scheme := pbes2.EncryptionScheme.Algorithm
+	var keySize int
+	switch {
+	case scheme.Equal(oidAES128CBC):
+		keySize = 16
+	case scheme.Equal(oidAES192CBC):
+		keySize = 24
+	case scheme.Equal(oidAES256CBC):
+		keySize = aes256KeySize
+	default:
+		return nil, nil, fmt.Errorf("unsupported PBES2 encryption scheme %s", scheme)
+	}
+	if kdf.KeyLength != 0 && kdf.KeyLength != keySize {
+		return nil, nil, fmt.Errorf("parsing PBKDF2 parameters: key length %d doesn't match the key size %d of %s",
+			kdf.KeyLength, keySize, scheme)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(pbes2.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, nil, fmt.Errorf("parsing PBES2 IV: %w", err)
+	}
+
+	key, err := pbkdf2.Key(prf.New, password, kdf.Salt, kdf.IterationCount, keySize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("deriving PBES2 key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, nil, errors.New("parsing PBES2 IV: invalid length")
+	}
+	return block, iv, nil
+}
+
+// tripleDESCipher returns the 3DES cipher and IV of the given pbeWithSHAAnd3-KeyTripleDES-CBC parameters.
+func tripleDESCipher(params []byte, password string) (cipher.Block, []byte, error) {
+	var pbe pbeParams
+	if _, err := asn1.Unmarshal(params, &pbe); err != nil {
+		return nil, nil, fmt.Errorf("parsing PBE parameters: %w", err)
+	}
+	if err := checkIterations(pbe.Iterations); err != nil {
+		return nil, nil, fmt.Errorf("parsing PBE parameters: %w", err)
+	}
+	bmp := bmpString(password)
+	key := pkcs12KDF(crypto.SHA1, pbe.Salt, bmp, pbe.Iterations, kdfEncryptionKey, tripleDESKeySize)
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	iv := pkcs12KDF(crypto.SHA1, pbe.Salt, bmp, pbe.Iterations, kdfIV, block.BlockSize())
+	return block, iv, nil
+}
+
+// encrypt encrypts the given data with the password using PBES2 with PBKDF2-HMAC-SHA256 and AES-256-CBC.
+// It returns the algorithm identifier with the parameters and the encrypted data.
+func encrypt(data []byte, password string, random io.Reader) (pkix.AlgorithmIdentifier, []byte, error) {
+	salt := make([]byte, saltSize)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(random, salt); err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, fmt.Errorf("generating salt: %w", err)
+	}
+	if _, err := io.ReadFull(random, iv); err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, fmt.Errorf("generating IV: %w", err)
+	}
+
+	key, err := pbkdf2.Key(crypto.SHA256.New, password, salt, pbkdf2Iterations, aes256KeySize)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, fmt.Errorf("deriving PBES2 key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	encrypted := append(bytes.Clone(data), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	params, err := marshalPBES2Params(salt, iv)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}}, encrypted, nil
+}
+
+// marshalPBES2Params returns the PBES2 parameters for PBKDF2-HMAC-SHA256 and AES-256-CBC with the given salt and IV.
+func marshalPBES2Params(salt []byte, iv []byte) ([]byte, error) {
+	kdf, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: pbkdf2Iterations,
+		KeyLength:      aes256KeySize,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBKDF2,
+			Parameters: asn1.RawValue{FullBytes: kdf},
+		},
+		EncryptionScheme: pkix.AlgorithmIdentifier{
+			Algorithm:  oidAES256CBC,
+			Parameters: asn1.RawValue{FullBytes: ivParams},
+		},
+	})
+}
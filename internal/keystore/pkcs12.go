@@ -0,0 +1,429 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package keystore reads and writes the keystore formats consumers of the rotated keys expect, i.e. PKCS#12 and
+// JKS, which is only written.
+package keystore
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // SHA-1 is used for the conventional local key ID, not for security
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+const (
+	// pfxVersion is the version of the PFX PDU defined in RFC 7292.
+	pfxVersion = 3
+	// macIterations is the number of iterations used to derive the MAC key of written keystores.
+	macIterations = 10000
+	// saltSize is the size of the salts of written keystores.
+	saltSize = 16
+	// tagBMPString is the ASN.1 tag of a BMPString.
+	tagBMPString = 30
+)
+
+// Object identifiers of RFC 7292 and the related PKCS standards.
+//
+//nolint:gochecknoglobals // read-only object identifiers
+var (
+	oidData                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidKeyBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Certificate     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+)
+
+// Entry is a private key with its certificate chain, stored under an alias.
+type Entry struct {
+	// Alias is stored as friendly name of the key and its certificate.
+	Alias string
+	// Key is the private key of the entry.
+	Key crypto.PrivateKey
+	// Certs is the certificate chain of the key, starting with the leaf certificate.
+	Certs []*x509.Certificate
+}
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	// Content is the explicitly tagged [0] content.
+	Content asn1.RawValue `asn1:"optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type safeBag struct {
+	ID asn1.ObjectIdentifier
+	// Value is the explicitly tagged [0] value of the bag.
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID asn1.ObjectIdentifier
+	// Values is the SET of values of the attribute.
+	Values asn1.RawValue
+}
+
+type certBag struct {
+	ID asn1.ObjectIdentifier
+	// Value is the explicitly tagged [0] OCTET STRING holding the DER encoded certificate.
+	Value asn1.RawValue
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// explicitTag wraps the given DER encoded value into an explicit [0] tag.
+func explicitTag(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// bmpString encodes the given string as null terminated BMPString, as used for the passwords of the PKCS#12 key
+// derivation function.
+func bmpString(s string) []byte {
+	encoded := make([]byte, 0, 2*len(s)+2) //nolint:mnd // two bytes per code unit and the terminator
+	for _, unit := range utf16.Encode([]rune(s)) {
+		encoded = append(encoded, byte(unit>>8), byte(unit)) //nolint:mnd // big endian
+	}
+	return append(encoded, 0, 0)
+}
+
+// DecodePKCS12 decodes a PKCS#12 keystore holding exactly one private key. It returns the key and the certificates
+// of the keystore, starting with the certificate of the key. Keys and certificates encrypted with PBES2 (PBKDF2 with
+// AES-CBC) or pbeWithSHAAnd3-KeyTripleDES-CBC are supported. The RC2 and RC4 algorithms of RFC 7292, e.g.
+// pbeWithSHAAnd40BitRC2-CBC used for the certificates of keystores written by openssl pkcs12 -legacy, are not.
+func DecodePKCS12(data []byte, password string) (crypto.PrivateKey, []*x509.Certificate, error) {
+	var pfx pfxPdu
+	if rest, err := asn1.Unmarshal(data, &pfx); err != nil {
+		return nil, nil, fmt.Errorf("parsing PKCS#12: %w", err)
+	} else if len(rest) > 0 {
+		return nil, nil, errors.New("parsing PKCS#12: trailing data")
+	}
+	if pfx.Version != pfxVersion || !pfx.AuthSafe.ContentType.Equal(oidData) {
+		return nil, nil, errors.New("parsing PKCS#12: only password integrity mode is supported")
+	}
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, nil, fmt.Errorf("parsing PKCS#12 auth safe: %w", err)
+	}
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) > 0 {
+		if err := verifyMac(pfx.MacData, authSafe, password); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	bags, err := decodeSafeBags(authSafe, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeKeyAndCerts(bags, password)
+}
+
+// decodeSafeBags decodes the safe bags of all contents of the auth safe, decrypting encrypted contents.
+func decodeSafeBags(authSafe []byte, password string) ([]safeBag, error) {
+	var contents []contentInfo
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return nil, fmt.Errorf("parsing PKCS#12 auth safe: %w", err)
+	}
+
+	var bags []safeBag
+	for _, content := range contents {
+		var safeContents []byte
+		switch {
+		case content.ContentType.Equal(oidData):
+			if _, err := asn1.Unmarshal(content.Content.Bytes, &safeContents); err != nil {
+				return nil, fmt.Errorf("parsing PKCS#12 data: %w", err)
+			}
+		case content.ContentType.Equal(oidEncryptedData):
+			var encrypted encryptedData
+			if _, err := asn1.Unmarshal(content.Content.Bytes, &encrypted); err != nil {
+				return nil, fmt.Errorf("parsing PKCS#12 encrypted data: %w", err)
+			}
+			info := encrypted.EncryptedContentInfo
+			var err error
+			safeContents, err = decrypt(info.ContentEncryptionAlgorithm, info.EncryptedContent, password)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("parsing PKCS#12: unsupported content type %s", content.ContentType)
+		}
+
+		var contentBags []safeBag
+		if _, err := asn1.Unmarshal(safeContents, &contentBags); err != nil {
+			return nil, fmt.Errorf("parsing PKCS#12 safe contents: %w", err)
+		}
+		bags = append(bags, contentBags...)
+	}
+	return bags, nil
+}
+
+// decodeKeyAndCerts returns the single private key and the certificates of the given safe bags, starting with the
+// certificate of the key.
+func decodeKeyAndCerts(bags []safeBag, password string) (crypto.PrivateKey, []*x509.Certificate, error) {
+	var keys []crypto.PrivateKey
+	var certs []*x509.Certificate
+	for _, bag := range bags {
+		switch {
+		case bag.ID.Equal(oidKeyBag), bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+			key, err := decodeKeyBag(bag, password)
+			if err != nil {
+				return nil, nil, err
+			}
+			keys = append(keys, key)
+		case bag.ID.Equal(oidCertBag):
+			var cb certBag
+			var der []byte
+			if _, err := asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil || !cb.ID.Equal(oidX509Certificate) {
+				return nil, nil, errors.New("parsing PKCS#12: unsupported certificate bag")
+			}
+			if _, err := asn1.Unmarshal(cb.Value.Bytes, &der); err != nil {
+				return nil, nil, fmt.Errorf("parsing PKCS#12 certificate bag: %w", err)
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parsing PKCS#12 certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		}
+	}
+	if len(keys) != 1 {
+		return nil, nil, fmt.Errorf("PKCS#12 must contain exactly one private key, found %d", len(keys))
+	}
+
+	// Move the certificate of the key to the front
+	public, ok := keys[0].(crypto.Signer)
+	for i, cert := range certs {
+		if key, isKey := cert.PublicKey.(interface{ Equal(x crypto.PublicKey) bool }); ok && isKey &&
+			key.Equal(public.Public()) {
+			certs[0], certs[i] = certs[i], certs[0]
+			break
+		}
+	}
+	return keys[0], certs, nil
+}
+
+// decodeKeyBag returns the private key of the given key bag or shrouded key bag.
+func decodeKeyBag(bag safeBag, password string) (crypto.PrivateKey, error) {
+	der := bag.Value.Bytes
+	if bag.ID.Equal(oidPKCS8ShroudedKeyBag) {
+		var info encryptedPrivateKeyInfo
+		if _, err := asn1.Unmarshal(der, &info); err != nil {
+			return nil, fmt.Errorf("parsing PKCS#12 shrouded key bag: %w", err)
+		}
+		var err error
+		if der, err = decrypt(info.Algorithm, info.EncryptedData, password); err != nil {
+			return nil, err
+		}
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parsing PKCS#12 private key: %w", err)
+	}
+	return key, nil
+}
+
+// verifyMac verifies the MAC of the auth safe with the given password.
+func verifyMac(mac macData, authSafe []byte, password string) error {
+	hash, err := hashOf(mac.Mac.Algorithm.Algorithm)
+	if err != nil {
+		return fmt.Errorf("verifying PKCS#12 MAC: %w", err)
+	}
+	if err = checkIterations(mac.Iterations); err != nil {
+		return fmt.Errorf("verifying PKCS#12 MAC: %w", err)
+	}
+	key := pkcs12KDF(hash, mac.MacSalt, bmpString(password), mac.Iterations, kdfMacKey, hash.Size())
+	h := hmac.New(hash.New, key)
+	h.Write(authSafe)
+	if !hmac.Equal(h.Sum(nil), mac.Mac.Digest) {
+		return errors.New("verifying PKCS#12 MAC: wrong password or corrupted keystore")
+	}
+	return nil
+}
+
+// EncodePKCS12 encodes the given entries into a PKCS#12 keystore protected by the given password. Keys and
+// certificates are encrypted with PBES2 (PBKDF2 with HMAC-SHA256 and AES-256-CBC), the integrity is protected by an
+// HMAC-SHA256. The salts and IVs are read from the given random source.
+func EncodePKCS12(entries []Entry, password string, random io.Reader) ([]byte, error) {
+	var keyBags, certBags []safeBag
+	for _, entry := range entries {
+		attributes, err := entryAttributes(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		keyBag, err := encodeKeyBag(entry.Key, password, random)
+		if err != nil {
+			return nil, err
+		}
+		keyBags = append(keyBags,
+			safeBag{ID: oidPKCS8ShroudedKeyBag, Value: explicitTag(keyBag), Attributes: attributes})
+		for j, cert := range entry.Certs {
+			var bag []byte
+			if bag, err = encodeCertBag(cert); err != nil {
+				return nil, err
+			}
+			certBag := safeBag{ID: oidCertBag, Value: explicitTag(bag)}
+			if j == 0 {
+				certBag.Attributes = attributes
+			}
+			certBags = append(certBags, certBag)
+		}
+	}
+
+	authSafe, err := encodeAuthSafe(keyBags, certBags, password, random)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := encodeMac(authSafe, password, random)
+	if err != nil {
+		return nil, err
+	}
+	content, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pfxPdu{
+		Version:  pfxVersion,
+		AuthSafe: contentInfo{ContentType: oidData, Content: explicitTag(content)},
+		MacData:  mac,
+	})
+}
+
+// entryAttributes returns the friendly name and local key ID attributes of an entry, which link its key to its
+// certificate. Like other tools, the SHA-1 hash of the leaf certificate is used as local key ID.
+func entryAttributes(entry Entry) ([]pkcs12Attribute, error) {
+	name := bmpString(entry.Alias)
+	friendlyName, err := asn1.Marshal(asn1.RawValue{Tag: tagBMPString, Bytes: name[:len(name)-2]})
+	if err != nil {
+		return nil, err
+	}
+	localKeyID := []byte(entry.Alias)
+	if len(entry.Certs) > 0 {
+		sum := sha1.Sum(entry.Certs[0].Raw) //nolint:gosec // not used for security
+		localKeyID = sum[:]
+	}
+	keyID, err := asn1.Marshal(localKeyID)
+	if err != nil {
+		return nil, err
+	}
+	return []pkcs12Attribute{
+		{ID: oidFriendlyName, Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: friendlyName}},
+		{ID: oidLocalKeyID, Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: keyID}},
+	}, nil
+}
+
+// encodeKeyBag returns the EncryptedPrivateKeyInfo of the given key.
+func encodeKeyBag(key crypto.PrivateKey, password string, random io.Reader) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("encoding private key: %w", err)
+	}
+	algorithm, encrypted, err := encrypt(der, password, random)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(encryptedPrivateKeyInfo{Algorithm: algorithm, EncryptedData: encrypted})
+}
+
+// encodeCertBag returns the CertBag of the given certificate.
+func encodeCertBag(cert *x509.Certificate) ([]byte, error) {
+	der, err := asn1.Marshal(cert.Raw)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(certBag{ID: oidX509Certificate, Value: explicitTag(der)})
+}
+
+// encodeAuthSafe returns the auth safe holding the given key bags unencrypted, as they are already shrouded, and the
+// given certificate bags encrypted.
+func encodeAuthSafe(keyBags []safeBag, certBags []safeBag, password string, random io.Reader) ([]byte, error) {
+	keys, err := asn1.Marshal(keyBags)
+	if err != nil {
+		return nil, err
+	}
+	keysContent, err := asn1.Marshal(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := asn1.Marshal(certBags)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, encrypted, err := encrypt(certs, password, random)
+	if err != nil {
+		return nil, err
+	}
+	certsContent, err := asn1.Marshal(encryptedData{EncryptedContentInfo: encryptedContentInfo{
+		ContentType:                oidData,
+		ContentEncryptionAlgorithm: algorithm,
+		EncryptedContent:           encrypted,
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal([]contentInfo{
+		{ContentType: oidEncryptedData, Content: explicitTag(certsContent)},
+		{ContentType: oidData, Content: explicitTag(keysContent)},
+	})
+}
+
+// encodeMac returns the HMAC-SHA256 of the auth safe with a key derived from the given password.
+func encodeMac(authSafe []byte, password string, random io.Reader) (macData, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(random, salt); err != nil {
+		return macData{}, fmt.Errorf("generating salt: %w", err)
+	}
+	key := pkcs12KDF(crypto.SHA256, salt, bmpString(password), macIterations, kdfMacKey, crypto.SHA256.Size())
+	h := hmac.New(crypto.SHA256.New, key)
+	h.Write(authSafe)
+	return macData{
+		Mac: digestInfo{
+			Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			Digest:    h.Sum(nil),
+		},
+		MacSalt:    salt,
+		Iterations: macIterations,
+	}, nil
+}
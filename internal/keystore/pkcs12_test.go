@@ -0,0 +1,270 @@
+// SPDX-FileCopyrightText: 2025 Deutsche Telekom IT GmbH
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package keystore
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math"
+	mathrand "math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"unicode/utf16"
+
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const testPassword = "changeit"
+
+// friendlyName returns the friendly name attribute of the given safe bag.
+func friendlyName(bag safeBag) string {
+	for _, attribute := range bag.Attributes {
+		if !attribute.ID.Equal(oidFriendlyName) {
+			continue
+		}
+		var value asn1.RawValue
+		_, err := asn1.Unmarshal(attribute.Values.Bytes, &value)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value.Tag).To(Equal(tagBMPString))
+		units := make([]uint16, 0, len(value.Bytes)/2)
+		for i := 0; i+1 < len(value.Bytes); i += 2 {
+			units = append(units, uint16(value.Bytes[i])<<8|uint16(value.Bytes[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	return ""
+}
+
+// decodeEntries decodes all keys and leaf certificates of a PKCS#12 keystore, keyed by their friendly name.
+func decodeEntries(data []byte, password string) (map[string]crypto.PrivateKey, map[string][]byte) {
+	var pfx pfxPdu
+	_, err := asn1.Unmarshal(data, &pfx)
+	Expect(err).NotTo(HaveOccurred())
+	var authSafe []byte
+	_, err = asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(verifyMac(pfx.MacData, authSafe, password)).To(Succeed())
+	bags, err := decodeSafeBags(authSafe, password)
+	Expect(err).NotTo(HaveOccurred())
+
+	keys := map[string]crypto.PrivateKey{}
+	leafs := map[string][]byte{}
+	for _, bag := range bags {
+		name := friendlyName(bag)
+		if name == "" {
+			continue
+		}
+		switch {
+		case bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+			key, err := decodeKeyBag(bag, password)
+			Expect(err).NotTo(HaveOccurred())
+			keys[name] = key
+		case bag.ID.Equal(oidCertBag):
+			var cb certBag
+			var der []byte
+			_, err := asn1.Unmarshal(bag.Value.Bytes, &cb)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = asn1.Unmarshal(cb.Value.Bytes, &der)
+			Expect(err).NotTo(HaveOccurred())
+			leafs[name] = der
+		}
+	}
+	return keys, leafs
+}
+
+// pbes2Algorithm returns the identifier of PBES2 with the given PBKDF2 parameters and AES-256-CBC.
+func pbes2Algorithm(kdf pbkdf2Params) pkix.AlgorithmIdentifier {
+	kdfParams, err := asn1.Marshal(kdf)
+	Expect(err).NotTo(HaveOccurred())
+	iv, err := asn1.Marshal(make([]byte, aes.BlockSize))
+	Expect(err).NotTo(HaveOccurred())
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBKDF2,
+			Parameters: asn1.RawValue{FullBytes: kdfParams},
+		},
+		EncryptionScheme: pkix.AlgorithmIdentifier{
+			Algorithm:  oidAES256CBC,
+			Parameters: asn1.RawValue{FullBytes: iv},
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
+	return pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}}
+}
+
+// tripleDESAlgorithm returns the identifier of pbeWithSHAAnd3-KeyTripleDES-CBC with the given iteration count.
+func tripleDESAlgorithm(iterations int) pkix.AlgorithmIdentifier {
+	params, err := asn1.Marshal(pbeParams{Salt: make([]byte, saltSize), Iterations: iterations})
+	Expect(err).NotTo(HaveOccurred())
+	return pkix.AlgorithmIdentifier{Algorithm: oidPBEWithSHAAnd3KeyTDES, Parameters: asn1.RawValue{FullBytes: params}}
+}
+
+var _ = ginkgo.Describe("PKCS#12", func() {
+	ginkgo.DescribeTable("decodes an encoded keystore",
+		func(newKey func() (crypto.Signer, error)) {
+			key, err := newKey()
+			Expect(err).NotTo(HaveOccurred())
+			entry := newTestEntry("kid", key)
+
+			encoded, err := EncodePKCS12([]Entry{entry}, testPassword, rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			decoded, certs, err := DecodePKCS12(encoded, testPassword)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(key.(privateKey).Equal(decoded)).To(BeTrue())
+			Expect(rawCerts(certs)).To(Equal(rawCerts(entry.Certs)))
+		},
+		ginkgo.Entry("with an ECDSA key", func() (crypto.Signer, error) {
+			return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		}),
+		ginkgo.Entry("with an RSA key", func() (crypto.Signer, error) {
+			return rsa.GenerateKey(rand.Reader, 2048)
+		}),
+		ginkgo.Entry("with an Ed25519 key", func() (crypto.Signer, error) {
+			_, key, err := ed25519.GenerateKey(rand.Reader)
+			return key, err
+		}),
+	)
+
+	ginkgo.It("stores every entry under its alias", func() {
+		keyA, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		keyB, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		entryA := newTestEntry("kid-a", keyA)
+		entryB := newTestEntry("kid-b", keyB)
+
+		encoded, err := EncodePKCS12([]Entry{entryA, entryB}, testPassword, rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		keys, leafs := decodeEntries(encoded, testPassword)
+		Expect(keys).To(HaveLen(2))
+		Expect(keyA.Equal(keys["kid-a"])).To(BeTrue())
+		Expect(keyB.Equal(keys["kid-b"])).To(BeTrue())
+		Expect(leafs).To(Equal(map[string][]byte{"kid-a": entryA.Certs[0].Raw, "kid-b": entryB.Certs[0].Raw}))
+	})
+
+	ginkgo.It("only depends on the entries, the password and the random source", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		entries := []Entry{newTestEntry("kid", key)}
+
+		first, err := EncodePKCS12(entries, testPassword, mathrand.NewChaCha8([32]byte{1}))
+		Expect(err).NotTo(HaveOccurred())
+		second, err := EncodePKCS12(entries, testPassword, mathrand.NewChaCha8([32]byte{1}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(first).To(Equal(second))
+	})
+
+	ginkgo.It("rejects a wrong password", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		encoded, err := EncodePKCS12([]Entry{newTestEntry("kid", key)}, testPassword, rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, err = DecodePKCS12(encoded, "wrong")
+		Expect(err).To(HaveOccurred())
+	})
+
+	ginkgo.Context("with crafted parameters", func() {
+		ginkgo.It("rejects a MAC iteration count above the limit", func() {
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			encoded, err := EncodePKCS12([]Entry{newTestEntry("kid", key)}, testPassword, rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			var pfx pfxPdu
+			_, err = asn1.Unmarshal(encoded, &pfx)
+			Expect(err).NotTo(HaveOccurred())
+			pfx.MacData.Iterations = math.MaxInt32
+			crafted, err := asn1.Marshal(pfx)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, err = DecodePKCS12(crafted, testPassword)
+			Expect(err).To(MatchError(ContainSubstring("unsupported iteration count")))
+		})
+
+		ginkgo.DescribeTable("rejects the encryption parameters before deriving a key",
+			func(algorithm pkix.AlgorithmIdentifier, expected string) {
+				_, err := decrypt(algorithm, make([]byte, aes.BlockSize), testPassword)
+				Expect(err).To(MatchError(ContainSubstring(expected)))
+			},
+			ginkgo.Entry("with a PBKDF2 iteration count above the limit",
+				pbes2Algorithm(pbkdf2Params{Salt: make([]byte, saltSize), IterationCount: math.MaxInt32}),
+				"unsupported iteration count"),
+			ginkgo.Entry("with a PBKDF2 iteration count of zero",
+				pbes2Algorithm(pbkdf2Params{Salt: make([]byte, saltSize)}),
+				"unsupported iteration count"),
+			ginkgo.Entry("with a PBKDF2 key length not matching the cipher",
+				pbes2Algorithm(pbkdf2Params{Salt: make([]byte, saltSize), IterationCount: 1, KeyLength: 16}),
+				"key length 16 doesn't match the key size 32"),
+			ginkgo.Entry("with a 3DES iteration count above the limit",
+				tripleDESAlgorithm(math.MaxInt32),
+				"unsupported iteration count"),
+		)
+	})
+
+	ginkgo.Context("with keystores written by OpenSSL", func() {
+		ginkgo.DescribeTable("decodes the key and the certificates",
+			func(file string) {
+				key, certs, err := DecodePKCS12(readTestdata(file), testPassword)
+				Expect(err).NotTo(HaveOccurred())
+
+				expected, err := x509.ParsePKCS8PrivateKey(readTestdataPEM("key.pem"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(expected.(privateKey).Equal(key)).To(BeTrue())
+				Expect(rawCerts(certs)).To(Equal([][]byte{readTestdataPEM("cert.pem"), readTestdataPEM("ca.pem")}))
+			},
+			ginkgo.Entry("encrypted with PBES2 and AES-256-CBC", "aes.p12"),
+			ginkgo.Entry("encrypted with pbeWithSHAAnd3-KeyTripleDES-CBC", "3des.p12"),
+		)
+
+		ginkgo.It("rejects keystores encrypted with RC2", func() {
+			_, _, err := DecodePKCS12(readTestdata("legacy.p12"), testPassword)
+			Expect(err).To(MatchError(ContainSubstring("unsupported PKCS#12 encryption algorithm")))
+		})
+	})
+
+	ginkgo.It("writes keystores OpenSSL can read", func() {
+		openssl, err := exec.LookPath("openssl")
+		if err != nil {
+			ginkgo.Skip("openssl is not installed")
+		}
+		keyA, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		keyB, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		entries := []Entry{newTestEntry("kid-a", keyA), newTestEntry("kid-b", keyB)}
+
+		encoded, err := EncodePKCS12(entries, testPassword, rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		file := filepath.Join(ginkgo.GinkgoT().TempDir(), "keystore.p12")
+		Expect(os.WriteFile(file, encoded, 0o600)).To(Succeed())
+
+		out, err := exec.Command(openssl, "pkcs12", "-in", file, "-passin", "pass:"+testPassword, //nolint:gosec // test
+			"-nodes", "-info").CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+		Expect(string(out)).To(ContainSubstring("friendlyName: kid-a"))
+		Expect(string(out)).To(ContainSubstring("friendlyName: kid-b"))
+		for _, entry := range entries {
+			for _, cert := range entry.Certs {
+				Expect(string(out)).To(ContainSubstring(string(pem.EncodeToMemory(
+					&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))))
+			}
+		}
+	})
+})